@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configEditor applies changes to the configuration file and writes it
+// back. Edits are serialized so two saves can't interleave.
+type configEditor struct {
+	path string
+	// load is how the saved configuration is finished, like on load. The
+	// categories directory is merged in, its files are never written.
+	load loadOptions
+	mu   sync.Mutex
+}
+
+// linkEntry is a link as seen by the edit API. Index is the position of
+// the link in the file, top-level links first and then those of each
+// group, which is how links are addressed when editing.
+type linkEntry struct {
+	Index int    `json:"index"`
+	Group string `json:"group,omitempty"`
+	Link
+}
+
+// adminData is what the admin template renders
+type adminData struct {
+	ConfigFile string
+	Entries    []linkEntry
+	Groups     []string
+	Error      string
+}
+
+// readRawConfig parses the configuration file as written, without the
+// load-time processing, so it can be edited and saved back
+func readRawConfig(path string) (Configuration, error) {
+	f, err := os.ReadFile(path)
+	if err != nil {
+		return Configuration{}, err
+	}
+	var config Configuration
+	if err := yaml.Unmarshal(f, &config); err != nil {
+		return Configuration{}, err
+	}
+	return config, nil
+}
+
+// linkEntries lists the links of config with their edit index
+func linkEntries(config Configuration) []linkEntry {
+	entries := make([]linkEntry, 0, config.linkCount())
+	for _, link := range config.Links {
+		entries = append(entries, linkEntry{Index: len(entries), Link: link})
+	}
+	for _, group := range config.Groups {
+		for _, link := range group.Links {
+			entries = append(entries, linkEntry{Index: len(entries), Group: group.Name, Link: link})
+		}
+	}
+	return entries
+}
+
+// locateLink returns the slice holding the link at index and the position
+// of the link in it
+func locateLink(config *Configuration, index int) (*[]Link, int, error) {
+	if index < 0 {
+		return nil, 0, fmt.Errorf("no link at index %d", index)
+	}
+	pos := index
+	if pos < len(config.Links) {
+		return &config.Links, pos, nil
+	}
+	pos -= len(config.Links)
+	for i := range config.Groups {
+		if pos < len(config.Groups[i].Links) {
+			return &config.Groups[i].Links, pos, nil
+		}
+		pos -= len(config.Groups[i].Links)
+	}
+	return nil, 0, fmt.Errorf("no link at index %d", index)
+}
+
+// validateLink checks the fields a link can't do without
+func validateLink(link Link) error {
+	if strings.TrimSpace(link.Name) == "" {
+		return errors.New("name is required")
+	}
+	u, err := url.Parse(link.Url)
+	if err != nil || u.Scheme == "" {
+		return fmt.Errorf("invalid url %q", link.Url)
+	}
+	return nil
+}
+
+// addLink appends link to group, creating the group if needed. An empty
+// group adds a top-level link.
+func addLink(config *Configuration, group string, link Link) {
+	if group == "" {
+		config.Links = append(config.Links, link)
+		return
+	}
+	for i := range config.Groups {
+		if config.Groups[i].Name == group {
+			config.Groups[i].Links = append(config.Groups[i].Links, link)
+			return
+		}
+	}
+	config.Groups = append(config.Groups, Group{Name: group, Links: []Link{link}})
+}
+
+// deleteLink removes the link at index, and its group when it was the
+// last link in it
+func deleteLink(config *Configuration, index int) error {
+	links, i, err := locateLink(config, index)
+	if err != nil {
+		return err
+	}
+	*links = append((*links)[:i], (*links)[i+1:]...)
+	config.Groups = slices.DeleteFunc(config.Groups, func(g Group) bool { return len(g.Links) == 0 })
+	return nil
+}
+
+// updateLink replaces the link at index, moving it when its group changed
+func updateLink(config *Configuration, index int, group string, link Link) error {
+	entries := linkEntries(*config)
+	if index < 0 || index >= len(entries) {
+		return fmt.Errorf("no link at index %d", index)
+	}
+	if entries[index].Group != group {
+		if err := deleteLink(config, index); err != nil {
+			return err
+		}
+		addLink(config, group, link)
+		return nil
+	}
+	links, i, err := locateLink(config, index)
+	if err != nil {
+		return err
+	}
+	(*links)[i] = link
+	return nil
+}
+
+// reorderLinks rearranges the links of a group, or the top-level links,
+// in the order of the given indexes. order must list every link of that
+// group exactly once.
+func reorderLinks(config *Configuration, order []int) error {
+	if len(order) == 0 {
+		return errors.New("order is empty")
+	}
+	links, _, err := locateLink(config, order[0])
+	if err != nil {
+		return err
+	}
+	if len(order) != len(*links) {
+		return fmt.Errorf("order lists %d links but the group of index %d has %d", len(order), order[0], len(*links))
+	}
+
+	reordered := make([]Link, 0, len(order))
+	seen := make(map[int]bool)
+	for _, index := range order {
+		group, i, err := locateLink(config, index)
+		if err != nil {
+			return err
+		}
+		if group != links || seen[index] {
+			return fmt.Errorf("index %d is listed twice or belongs to another group", index)
+		}
+		seen[index] = true
+		reordered = append(reordered, (*links)[i])
+	}
+	*links = reordered
+	return nil
+}
+
+// atomicTempPattern names the temporary files of writeFileAtomic
+const atomicTempPattern = ".*.tmp-*"
+
+// isAtomicTempFile reports whether path is a temporary file left by
+// writeFileAtomic while it writes
+func isAtomicTempFile(path string) bool {
+	matched, _ := filepath.Match(atomicTempPattern, filepath.Base(path))
+	return matched
+}
+
+// writeFileAtomic replaces path with data by writing a temporary file next
+// to it and renaming it into place, so a crash never leaves a truncated
+// file behind
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// Sync the directory too so the rename itself survives a crash
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// marshalConfig encodes config as YAML with the two-space indentation
+// config files are usually written with. The comments and key order of
+// original, the file being replaced, are kept where they still apply.
+func marshalConfig(config Configuration, original []byte) ([]byte, error) {
+	var updated yaml.Node
+	if err := updated.Encode(config); err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(original, &doc); err == nil && len(doc.Content) == 1 {
+		preserveLayout(doc.Content[0], &updated)
+		keepUnknownKeys(doc.Content[0], &updated)
+		doc.Content[0] = &updated
+	} else {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&updated}}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// edit applies fn to the configuration file and saves the result. The new
+// file goes through the same processing as a load before being written,
+// so an edit can't save a configuration the server would refuse.
+func (e *configEditor) edit(fn func(*Configuration) error) (LoadResult, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	original, err := os.ReadFile(e.path)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	var raw Configuration
+	if err := yaml.Unmarshal(original, &raw); err != nil {
+		return LoadResult{}, err
+	}
+	if err := fn(&raw); err != nil {
+		return LoadResult{}, err
+	}
+	data, err := marshalConfig(raw, original)
+	if err != nil {
+		return LoadResult{}, err
+	}
+
+	result, err := assembleConfig([]configFile{{source: e.path, data: data}}, e.load)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	if err := writeFileAtomic(e.path, data); err != nil {
+		return LoadResult{}, fmt.Errorf("failed to save configuration: %w", err)
+	}
+	return result, nil
+}
+
+// isJSONRequest tells API clients apart from admin page form posts
+func isJSONRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get("Content-Type"), "application/json")
+}
+
+// parseLinkEntry reads a link edit from a JSON body or a form
+func parseLinkEntry(req *http.Request) (linkEntry, error) {
+	var entry linkEntry
+	if isJSONRequest(req) {
+		if err := json.NewDecoder(req.Body).Decode(&entry); err != nil {
+			return entry, fmt.Errorf("invalid JSON body: %w", err)
+		}
+		return entry, nil
+	}
+
+	if err := req.ParseForm(); err != nil {
+		return entry, err
+	}
+	if index := req.PostFormValue("index"); index != "" {
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			return entry, fmt.Errorf("invalid index %q", index)
+		}
+		entry.Index = i
+	}
+	entry.Group = strings.TrimSpace(req.PostFormValue("group"))
+	entry.Name = strings.TrimSpace(req.PostFormValue("name"))
+	entry.Url = strings.TrimSpace(req.PostFormValue("url"))
+	entry.Description = strings.TrimSpace(req.PostFormValue("description"))
+	entry.Alias = strings.TrimSpace(req.PostFormValue("alias"))
+	entry.Auth = req.PostFormValue("auth") != ""
+	entry.Copyable = req.PostFormValue("copyable") != ""
+	entry.NewTab = req.PostFormValue("new_tab") != ""
+	entry.Rel = strings.TrimSpace(req.PostFormValue("rel"))
+	entry.NoAutoDescription = req.PostFormValue("no_auto_description") != ""
+	entry.Key = strings.TrimSpace(req.PostFormValue("key"))
+	entry.Preview = req.PostFormValue("preview") != ""
+	entry.Icon = strings.TrimSpace(req.PostFormValue("icon"))
+	entry.Class = strings.TrimSpace(req.PostFormValue("class"))
+	entry.Aliases = splitList(req.PostFormValue("aliases"))
+	entry.Tags = splitList(req.PostFormValue("tags"))
+	for _, code := range splitList(req.PostFormValue("expect_status")) {
+		status, err := strconv.Atoi(code)
+		if err != nil {
+			return entry, fmt.Errorf("invalid expected status %q", code)
+		}
+		entry.ExpectStatus = append(entry.ExpectStatus, status)
+	}
+	return entry, nil
+}
+
+// splitList splits a comma-separated form value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// renderAdmin renders the admin page, with errMsg shown above the forms
+func (h *Handler) renderAdmin(w http.ResponseWriter, status int, errMsg string) {
+	raw, err := readRawConfig(h.editor.path)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading configuration: %v", err), http.StatusInternalServerError)
+		return
+	}
+	data := adminData{
+		ConfigFile: h.editor.path,
+		Entries:    linkEntries(raw),
+		Error:      errMsg,
+	}
+	for _, group := range raw.Groups {
+		data.Groups = append(data.Groups, group.Name)
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(status)
+	_, tmpl := h.snapshot()
+	if err := tmpl.ExecuteTemplate(w, "admin.html", data); err != nil {
+		http.Error(w, fmt.Sprintf("Error rendering template: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) admin(w http.ResponseWriter, req *http.Request) {
+	h.renderAdmin(w, http.StatusOK, "")
+}
+
+// applyEdit runs an edit and reports the outcome
+func (h *Handler) applyEdit(w http.ResponseWriter, req *http.Request, fn func(*Configuration) error) {
+	result, err := h.editor.edit(fn)
+	if err == nil {
+		h.updateConfig(result)
+	}
+	h.respondEdit(w, req, err)
+}
+
+// respondEdit answers an edit request: JSON clients get the updated links
+// or the error, form posts go back to the admin page
+func (h *Handler) respondEdit(w http.ResponseWriter, req *http.Request, err error) {
+	if !isJSONRequest(req) {
+		if err != nil {
+			h.renderAdmin(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		http.Redirect(w, req, "/admin", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	h.writeLinkEntries(w)
+}
+
+// writeLinkEntries writes the links of the configuration file as JSON
+func (h *Handler) writeLinkEntries(w http.ResponseWriter) {
+	raw, err := readRawConfig(h.editor.path)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading configuration: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(linkEntries(raw))
+}
+
+// apiLinks lists the links of the configuration file on GET and adds one
+// on POST
+func (h *Handler) apiLinks(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		h.writeLinkEntries(w)
+		return
+	}
+
+	entry, err := parseLinkEntry(req)
+	if err == nil {
+		err = validateLink(entry.Link)
+	}
+	if err != nil {
+		h.respondEdit(w, req, err)
+		return
+	}
+	h.applyEdit(w, req, func(config *Configuration) error {
+		addLink(config, entry.Group, entry.Link)
+		return nil
+	})
+}
+
+func (h *Handler) apiUpdateLink(w http.ResponseWriter, req *http.Request) {
+	entry, err := parseLinkEntry(req)
+	if err == nil {
+		err = validateLink(entry.Link)
+	}
+	if err != nil {
+		h.respondEdit(w, req, err)
+		return
+	}
+	h.applyEdit(w, req, func(config *Configuration) error {
+		return updateLink(config, entry.Index, entry.Group, entry.Link)
+	})
+}
+
+func (h *Handler) apiDeleteLink(w http.ResponseWriter, req *http.Request) {
+	entry, err := parseLinkEntry(req)
+	if err != nil {
+		h.respondEdit(w, req, err)
+		return
+	}
+	h.applyEdit(w, req, func(config *Configuration) error {
+		return deleteLink(config, entry.Index)
+	})
+}
+
+// apiReorderLinks rearranges the links of a group from a JSON body
+// listing their indexes in the new order
+func (h *Handler) apiReorderLinks(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Order []int `json:"order"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		h.respondEdit(w, req, fmt.Errorf("invalid JSON body: %w", err))
+		return
+	}
+	h.applyEdit(w, req, func(config *Configuration) error {
+		return reorderLinks(config, body.Order)
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newEditingHandler returns a handler serving the YAML configuration
+// content from a temporary file, editable through the admin API, and the
+// path of that file
+func newEditingHandler(t *testing.T, content string) (*Handler, string) {
+	t.Helper()
+	path := writeFile(t, t.TempDir(), "config.yaml", content)
+	result, err := loadConfig(path, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandler(t, result.Config)
+	h.configFile = path
+	h.editor = &configEditor{path: path}
+	return h, path
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "title: Old\n")
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(strings.Repeat("links:\n  - {name: Router, url: http://router.local}\n", 1000))
+	if err := writeFileAtomic(path, data); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("the file holds %d bytes, want the %d written", len(got), len(data))
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("the file mode was not kept: %v", info.Mode())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("the directory holds %d files, want only the config file", len(entries))
+	}
+}
+
+func TestWriteFileAtomicFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "config.yaml")
+	if err := writeFileAtomic(path, []byte("title: Home\n")); err == nil {
+		t.Fatal("writing into a missing directory succeeded")
+	}
+}
+
+func TestIsAtomicTempFile(t *testing.T) {
+	dir := t.TempDir()
+	tmp, err := os.CreateTemp(dir, ".config.yaml.tmp-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Close()
+	tests := map[string]bool{
+		tmp.Name():                            true,
+		filepath.Join(dir, "config.yaml"):     false,
+		filepath.Join(dir, "config.yaml~"):    false,
+		filepath.Join(dir, "config.tmp.yaml"): false,
+	}
+	for path, want := range tests {
+		if got := isAtomicTempFile(path); got != want {
+			t.Errorf("isAtomicTempFile(%s) = %v, want %v", filepath.Base(path), got, want)
+		}
+	}
+}
+
+func TestEditLeavesNoTempFile(t *testing.T) {
+	h, path := newEditingHandler(t, "links:\n  - {name: Router, url: http://router.local}\n")
+	if _, err := h.editor.edit(func(c *Configuration) error {
+		c.Links = append(c.Links, Link{Name: "Wiki", Url: "http://wiki.local"})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := loadConfig(path, loadOptions{})
+	if err != nil {
+		t.Fatalf("the saved file doesn't load: %v", err)
+	}
+	if got := linkNames(result.Config.Links); !reflect.DeepEqual(got, []string{"Router", "Wiki"}) {
+		t.Errorf("saved links = %v, want [Router Wiki]", got)
+	}
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), atomicTempPattern))
+	if len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestAdminPage(t *testing.T) {
+	h, path := newEditingHandler(t, `
+links:
+  - {name: Router, url: "http://router.local"}
+groups:
+  - name: Media
+    links:
+      - {name: Jellyfin, url: "http://jellyfin.local", alias: tv}
+`)
+	mux := newTestMux(t, h, AppConfig{AuthUser: "admin", AuthPass: "secret"})
+	request := func(method, target string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, body)
+		req.SetBasicAuth("admin", "secret")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(t, mux, "/admin"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /admin without credentials = %d, want 401", rec.Code)
+	}
+	rec := request(http.MethodGet, "/admin", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /admin = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`value="Router"`,
+		`value="http://router.local"`,
+		`value="Jellyfin"`,
+		`name="group" value="Media"`,
+		`name="alias" value="tv"`,
+		`<form id="edit-0" method="post" action="/api/links/update">`,
+		`<form method="post" action="/api/links/delete">`,
+		`<form method="post" action="/api/links">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("the admin page does not contain %s", want)
+		}
+	}
+
+	// The add form posts back to the API, which redirects to the page
+	rec = request(http.MethodPost, "/api/links", strings.NewReader("name=Wiki&url=http%3A%2F%2Fwiki.local&group=Media"))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/admin" {
+		t.Fatalf("POST /api/links from the form = %d %q, want a redirect to /admin", rec.Code, rec.Header().Get("Location"))
+	}
+	result, err := loadConfig(path, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := linkNames(result.Config.Groups[0].Links); !reflect.DeepEqual(got, []string{"Jellyfin", "Wiki"}) {
+		t.Errorf("Media links after the form post = %v, want [Jellyfin Wiki]", got)
+	}
+	if !strings.Contains(request(http.MethodGet, "/admin", nil).Body.String(), `value="Wiki"`) {
+		t.Error("the admin page doesn't list the added link")
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// buildAliases maps every alias of every link to the link URL. Two links claiming
+// the same alias is an error, as only one of them could be reached.
+func buildAliases(config Configuration) (map[string]string, error) {
+	aliases := make(map[string]string)
+	owners := make(map[string]string)
+
+	var err error
+	forEachLink(config, func(link Link) {
+		for _, alias := range link.aliasNames() {
+			if err != nil {
+				return
+			}
+			if strings.Contains(alias, "/") {
+				err = fmt.Errorf("invalid alias %q for %q: aliases can't contain '/'", alias, link.Name)
+				return
+			}
+			if isReservedName(alias) {
+				err = fmt.Errorf("invalid alias %q for %q: /%s is a route of the server", alias, link.Name, alias)
+				return
+			}
+			if owner, ok := owners[alias]; ok {
+				err = fmt.Errorf("alias %q is used by both %q and %q", alias, owner, link.Name)
+				return
+			}
+			owners[alias] = link.Name
+			aliases[alias] = link.Url
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
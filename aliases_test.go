@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// loadString loads the YAML configuration content with opts
+func loadString(t *testing.T, content string, opts loadOptions) (LoadResult, error) {
+	t.Helper()
+	return loadConfig(writeFile(t, t.TempDir(), "config.yaml", content), opts)
+}
+
+func TestAliasRedirect(t *testing.T) {
+	result, err := loadString(t, `
+links:
+  - {name: Grafana, url: "http://grafana.local/d/home", alias: g}
+groups:
+  - name: Media
+    links:
+      - {name: Jellyfin, url: "http://jellyfin.local", alias: tv}
+`, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := http.HandlerFunc(newTestHandler(t, result.Config).index)
+	for target, want := range map[string]string{"/g": "http://grafana.local/d/home", "/tv": "http://jellyfin.local"} {
+		rec := get(t, index, target)
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != want {
+			t.Errorf("GET %s = %d to %q, want 302 to %q", target, rec.Code, rec.Header().Get("Location"), want)
+		}
+	}
+	if rec := get(t, index, "/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /unknown = %d, want 404", rec.Code)
+	}
+}
+
+func TestAliasCollision(t *testing.T) {
+	const content = `
+links:
+  - {name: Grafana, url: "http://grafana.local", alias: g}
+groups:
+  - name: Code
+    links:
+      - {name: GitLab, url: "http://gitlab.local", alias: g}
+`
+	result, err := loadString(t, content, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.Aliases["g"]; got != "http://grafana.local" {
+		t.Errorf("alias g goes to %q, want the first link", got)
+	}
+	if gitlab := result.Config.Groups[0].Links[0]; gitlab.Alias != "" {
+		t.Errorf("GitLab kept alias %q, want it dropped", gitlab.Alias)
+	}
+	want := Warning{Link: "GitLab", Message: `alias "g" is already used by "Grafana"`}
+	if len(result.Warnings) != 1 || result.Warnings[0] != want {
+		t.Errorf("warnings = %v, want [%v]", result.Warnings, want)
+	}
+
+	_, err = loadString(t, content, loadOptions{strict: true})
+	if err == nil || !strings.Contains(err.Error(), want.Message) {
+		t.Errorf("strict load error = %v, want the collision", err)
+	}
+}
+
+func TestMultipleAliases(t *testing.T) {
+	result, err := loadString(t, `
+links:
+  - {name: Grafana, url: "http://grafana.local", alias: g, aliases: [graf, dash]}
+  - {name: Wiki, url: "http://wiki.local", aliases: [w, docs]}
+`, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := http.HandlerFunc(newTestHandler(t, result.Config).index)
+	for alias, want := range map[string]string{
+		"g": "http://grafana.local", "graf": "http://grafana.local", "dash": "http://grafana.local",
+		"w": "http://wiki.local", "docs": "http://wiki.local",
+	} {
+		rec := get(t, index, "/"+alias)
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != want {
+			t.Errorf("GET /%s = %d to %q, want 302 to %q", alias, rec.Code, rec.Header().Get("Location"), want)
+		}
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("warnings = %v, want none", result.Warnings)
+	}
+}
+
+func TestMultipleAliasesCollision(t *testing.T) {
+	result, err := loadString(t, `
+links:
+  - {name: Grafana, url: "http://grafana.local", aliases: [graf, dash]}
+  - {name: Dashy, url: "http://dashy.local", aliases: [dash, home]}
+`, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.Aliases["dash"]; got != "http://grafana.local" {
+		t.Errorf("alias dash goes to %q, want the first link", got)
+	}
+	if got := result.Config.Aliases["home"]; got != "http://dashy.local" {
+		t.Errorf("alias home goes to %q, want Dashy to keep its other aliases", got)
+	}
+	if got := result.Config.Links[1].Aliases; !reflect.DeepEqual(got, []string{"home"}) {
+		t.Errorf("Dashy aliases = %v, want [home]", got)
+	}
+	want := Warning{Link: "Dashy", Message: `alias "dash" is already used by "Grafana"`}
+	if len(result.Warnings) != 1 || result.Warnings[0] != want {
+		t.Errorf("warnings = %v, want [%v]", result.Warnings, want)
+	}
+}
+
+func TestBuildAliasesCollision(t *testing.T) {
+	config := Configuration{Links: []Link{
+		{Name: "A", Url: "http://a.local", Aliases: []string{"x"}},
+		{Name: "B", Url: "http://b.local", Alias: "x"},
+	}}
+	if _, err := buildAliases(config); err == nil || !strings.Contains(err.Error(), `alias "x" is used by both "A" and "B"`) {
+		t.Errorf("buildAliases error = %v, want the collision", err)
+	}
+}
+
+func TestReservedAlias(t *testing.T) {
+	for _, alias := range []string{"healthz", "metrics", "api", "admin", "whoami", "sw.js", "favicons"} {
+		t.Run(alias, func(t *testing.T) {
+			result, err := loadString(t, "links:\n  - {name: Grafana, url: \"http://grafana.local\", aliases: [g, "+alias+"]}\n", loadOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := result.Config.Aliases[alias]; ok {
+				t.Errorf("alias %q was kept", alias)
+			}
+			if got := result.Config.Links[0].Aliases; !reflect.DeepEqual(got, []string{"g"}) {
+				t.Errorf("aliases = %v, want [g]", got)
+			}
+			want := Warning{Link: "Grafana", Message: fmt.Sprintf("alias %q is taken by a route of the server", alias)}
+			if len(result.Warnings) != 1 || result.Warnings[0] != want {
+				t.Errorf("warnings = %v, want [%v]", result.Warnings, want)
+			}
+		})
+	}
+
+	if _, err := loadString(t, "links:\n  - {name: Metrics, url: \"http://grafana.local\", alias: metrics}\n", loadOptions{strict: true}); err == nil {
+		t.Error("a reserved alias was accepted under -strict")
+	}
+	if _, err := buildAliases(Configuration{Links: []Link{{Name: "A", Url: "http://a.local", Alias: "ping"}}}); err == nil {
+		t.Error("buildAliases accepted the alias ping")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// isCategoryFile reports whether name is a YAML file loaded from the
+// categories directory
+func isCategoryFile(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// loadCategories reads the groups defined in dir, one per YAML file in
+// lexical order. A group without a name is named after its file. The raw
+// files are written to hash so the configuration hash covers them.
+func loadCategories(dir string, hash io.Writer) (Configuration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return Configuration{}, fmt.Errorf("failed to read categories dir: %w", err)
+	}
+
+	var config Configuration
+	for _, entry := range entries {
+		if entry.IsDir() || !isCategoryFile(entry.Name()) {
+			continue
+		}
+		file := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return Configuration{}, err
+		}
+		var group Group
+		if err := yaml.Unmarshal(data, &group); err != nil {
+			return Configuration{}, fmt.Errorf("%s: %w", file, err)
+		}
+		if group.Name == "" {
+			group.Name = strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		}
+		hash.Write(data)
+		config = mergeConfigs(config, Configuration{Groups: []Group{group}})
+	}
+	return config, nil
+}
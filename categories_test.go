@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCategoriesDir(t *testing.T) {
+	dir := t.TempDir()
+	categories := t.TempDir()
+	writeFile(t, categories, "media.yaml", "links:\n  - {name: Jellyfin, url: http://jellyfin.local}\n")
+	writeFile(t, categories, "tools.yml", "name: Dev tools\nlinks:\n  - {name: Gitea, url: http://gitea.local}\n")
+	writeFile(t, categories, "README.md", "not a category\n")
+	configFile := writeFile(t, dir, "config.yaml", "links:\n  - {name: Router, url: http://router.local}\n")
+
+	result, err := loadConfig(configFile, loadOptions{categoriesDir: categories})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := groupNames(result.Config.Groups); !reflect.DeepEqual(got, []string{"media", "Dev tools"}) {
+		t.Fatalf("groups = %v, want [media Dev tools]", got)
+	}
+
+	body := renderIndex(t, result.Config)
+	if n := strings.Count(body, `<section class="group`); n != 2 {
+		t.Errorf("%d sections rendered, want 2", n)
+	}
+	for _, want := range []string{">media</h2>", ">Dev tools</h2>", ">Jellyfin</a>", ">Gitea</a>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("the page does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// isConfigPattern reports whether source is a glob such as conf.d/*.yaml
+func isConfigPattern(source string) bool {
+	return !isRemoteConfig(source) && strings.ContainsAny(source, "*?[")
+}
+
+// expandConfigSource returns the files source refers to. Globs expand to
+// their matches in lexical order, anything else is returned as is.
+func expandConfigSource(source string) ([]string, error) {
+	if !isConfigPattern(source) {
+		return []string{source}, nil
+	}
+	matches, err := filepath.Glob(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config pattern %q: %w", source, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("config pattern %q matches no files", source)
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
+
+// mergeConfigs appends the links, groups and rules of src to dst. Groups
+// sharing a name are combined into one. Settings src sets override the
+// ones of dst.
+func mergeConfigs(dst, src Configuration) Configuration {
+	if src.Columns != 0 {
+		dst.Columns = src.Columns
+	}
+	if src.IconSize != 0 {
+		dst.IconSize = src.IconSize
+	}
+	if src.Refresh != 0 {
+		dst.Refresh = src.Refresh
+	}
+	if !reflect.DeepEqual(src.Defaults, Link{}) {
+		dst.Defaults = src.Defaults
+	}
+	if len(src.AppIcons) > 0 {
+		dst.AppIcons = src.AppIcons
+	}
+	if len(src.IconFallback) > 0 {
+		dst.IconFallback = src.IconFallback
+	}
+	if len(src.SearchFields) > 0 {
+		dst.SearchFields = src.SearchFields
+	}
+	if src.Title != "" {
+		dst.Title = src.Title
+	}
+	dst.Pages = mergePages(dst.Pages, src.Pages)
+	if src.HomeURL != "" {
+		dst.HomeURL = src.HomeURL
+	}
+
+	dst.Links = append(dst.Links, src.Links...)
+	dst.AutoGroups = append(dst.AutoGroups, src.AutoGroups...)
+	dst.Sources = append(dst.Sources, src.Sources...)
+	dst.HeaderRules = append(dst.HeaderRules, src.HeaderRules...)
+	dst.GroupOrder = append(dst.GroupOrder, src.GroupOrder...)
+
+	for _, group := range src.Groups {
+		i := slices.IndexFunc(dst.Groups, func(g Group) bool { return g.Name == group.Name })
+		if i < 0 {
+			dst.Groups = append(dst.Groups, group)
+			continue
+		}
+		dst.Groups[i].Links = append(dst.Groups[i].Links, group.Links...)
+		if group.Span != 0 {
+			dst.Groups[i].Span = group.Span
+		}
+		if group.OpenAll {
+			dst.Groups[i].OpenAll = true
+		}
+	}
+	return dst
+}
+
+// dedupeLinks returns config without the links whose URL already appeared
+// earlier on the same page, top-level links coming before the groups, and
+// a warning for each link dropped. The order of the others is kept, a
+// group is dropped when all its links are, and config is left untouched.
+func dedupeLinks(config Configuration) (Configuration, []Warning) {
+	var warnings []Warning
+	dedupePage := func(links []Link, groups []Group) ([]Link, []Group) {
+		seen := make(map[string]string)
+		keep := func(links []Link) []Link {
+			var kept []Link
+			for _, link := range links {
+				if first, ok := seen[link.Url]; ok {
+					warnings = append(warnings, Warning{Link: link.Name, Message: fmt.Sprintf("duplicate of %q, same URL %s", first, link.Url)})
+					continue
+				}
+				seen[link.Url] = link.Name
+				kept = append(kept, link)
+			}
+			return kept
+		}
+		links = keep(links)
+		var kept []Group
+		for _, group := range groups {
+			// A group left empty by the duplicates goes with them
+			before := len(group.Links)
+			if group.Links = keep(group.Links); before == 0 || len(group.Links) > 0 {
+				kept = append(kept, group)
+			}
+		}
+		return links, kept
+	}
+
+	config.Links, config.Groups = dedupePage(config.Links, config.Groups)
+	if config.Pages != nil {
+		pages := make(map[string]Page, len(config.Pages))
+		for _, name := range pageNames(config) {
+			page := config.Pages[name]
+			page.Links, page.Groups = dedupePage(page.Links, page.Groups)
+			pages[name] = page
+		}
+		config.Pages = pages
+	}
+	return config, warnings
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "20-work.yaml", "links:\n  - {name: Jira, url: http://jira.local}\ngroups:\n  - name: Shared\n    links:\n      - {name: Wiki, url: http://wiki.local}\n")
+	writeFile(t, dir, "10-home.yaml", "title: Home\nlinks:\n  - {name: Router, url: http://router.local}\ngroups:\n  - name: Shared\n    links:\n      - {name: NAS, url: http://nas.local}\n")
+	writeFile(t, dir, "notes.txt", "http://ignored.local\n")
+
+	result, err := loadConfig(filepath.Join(dir, "*.yaml"), loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := result.Config
+	if config.Title != "Home" {
+		t.Errorf("title = %q, want Home", config.Title)
+	}
+	if got := linkNames(config.Links); !reflect.DeepEqual(got, []string{"Router", "Jira"}) {
+		t.Errorf("links = %v, want the files merged in lexical order", got)
+	}
+	if len(config.Groups) != 1 || !reflect.DeepEqual(linkNames(config.Groups[0].Links), []string{"NAS", "Wiki"}) {
+		t.Errorf("groups = %+v, want one Shared group with NAS then Wiki", config.Groups)
+	}
+	if len(result.files) != 2 {
+		t.Errorf("%d files read, want 2", len(result.files))
+	}
+}
+
+func TestExpandConfigSource(t *testing.T) {
+	dir := t.TempDir()
+	b := writeFile(t, dir, "b.yaml", "")
+	a := writeFile(t, dir, "a.yaml", "")
+
+	matches, err := expandConfigSource(filepath.Join(dir, "*.yaml"))
+	if err != nil || !reflect.DeepEqual(matches, []string{a, b}) {
+		t.Errorf("expandConfigSource(*.yaml) = %v, %v, want [%s %s]", matches, err, a, b)
+	}
+	if _, err := expandConfigSource(filepath.Join(dir, "*.json")); err == nil {
+		t.Error("a pattern matching nothing is not an error")
+	}
+	if _, err := expandConfigSource(filepath.Join(dir, "[.yaml")); err == nil {
+		t.Error("an invalid pattern is not an error")
+	}
+	for _, source := range []string{"config.yaml", "http://config.local/*.yaml", "-"} {
+		if got, err := expandConfigSource(source); err != nil || !reflect.DeepEqual(got, []string{source}) {
+			t.Errorf("expandConfigSource(%q) = %v, %v, want it unchanged", source, got, err)
+		}
+	}
+}
+
+// withStdin makes content the standard input for the rest of the test
+func withStdin(t *testing.T, content string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		io.WriteString(w, content)
+		w.Close()
+	}()
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		r.Close()
+	})
+}
+
+func TestLoadConfigStdin(t *testing.T) {
+	tests := map[string]string{
+		"yaml": "title: Piped\nlinks:\n  - {name: Router, url: http://router.local}\n",
+		"json": `{"title": "Piped", "links": [{"name": "Router", "url": "http://router.local"}]}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			withStdin(t, content)
+			result, err := loadConfig(stdinConfig, loadOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Config.Title != "Piped" || !reflect.DeepEqual(linkNames(result.Config.Links), []string{"Router"}) {
+				t.Errorf("config from stdin = %+v", result.Config)
+			}
+		})
+	}
+}
+
+func TestReloadStdin(t *testing.T) {
+	h := newTestHandler(t, Configuration{})
+	h.configFile = stdinConfig
+	mux := newTestMux(t, h, AppConfig{ReloadToken: "token"})
+	req := httptest.NewRequest(http.MethodPost, "/api/reload", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "stdin") {
+		t.Errorf("reload of a stdin configuration = %d %s, want 422", rec.Code, rec.Body)
+	}
+}
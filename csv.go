@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Link fields a CSV column can be mapped to
+const (
+	csvName        = "name"
+	csvURL         = "url"
+	csvGroup       = "group"
+	csvDescription = "description"
+	csvTags        = "tags"
+)
+
+var csvFields = []string{csvName, csvURL, csvGroup, csvDescription, csvTags}
+
+// parseCSVColumns parses -csv-columns, a comma separated list of
+// field=header pairs naming the CSV column each link field is read from.
+// Fields left out are read from the column named after them.
+func parseCSVColumns(spec string) (map[string]string, error) {
+	columns := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, header, ok := strings.Cut(pair, "=")
+		field, header = strings.TrimSpace(field), strings.TrimSpace(header)
+		if !ok || header == "" {
+			return nil, fmt.Errorf("%q is not a field=header pair", pair)
+		}
+		if !slices.Contains(csvFields, field) {
+			return nil, fmt.Errorf("unknown field %q: must be one of %s", field, strings.Join(csvFields, ", "))
+		}
+		columns[field] = header
+	}
+	return columns, nil
+}
+
+// decodeCSVConfig parses a CSV export of links. The first row is the
+// header, columns is parseCSVColumns' mapping of fields to header names.
+// Links with a group go to that group, in the order groups first appear,
+// tags are separated by ';'. Rows missing a name or a URL, or with the
+// wrong number of columns, are skipped with a warning.
+func decodeCSVConfig(data []byte, columns map[string]string) (Configuration, []Warning, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return Configuration{}, nil, fmt.Errorf("invalid header: %w", err)
+	}
+	index := make(map[string]int)
+	for _, field := range csvFields {
+		index[field] = slices.IndexFunc(header, func(column string) bool {
+			return strings.EqualFold(strings.TrimSpace(column), cmp.Or(columns[field], field))
+		})
+	}
+	for _, field := range []string{csvName, csvURL} {
+		if index[field] < 0 {
+			return Configuration{}, nil, fmt.Errorf("no %q column in the header", cmp.Or(columns[field], field))
+		}
+	}
+
+	var config Configuration
+	var warnings []Warning
+	groups := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return Configuration{}, nil, err
+			}
+			warnings = append(warnings, Warning{Message: fmt.Sprintf("line %d skipped: %v", parseErr.Line, parseErr.Err)})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		value := func(field string) string {
+			if index[field] < 0 {
+				return ""
+			}
+			return strings.TrimSpace(record[index[field]])
+		}
+		link := Link{Name: value(csvName), Url: value(csvURL), Description: value(csvDescription)}
+		if link.Name == "" || link.Url == "" {
+			warnings = append(warnings, Warning{Link: link.Name, Message: fmt.Sprintf("line %d skipped: missing name or URL", line)})
+			continue
+		}
+		for _, tag := range strings.Split(value(csvTags), ";") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				link.Tags = append(link.Tags, tag)
+			}
+		}
+
+		group := value(csvGroup)
+		if group == "" {
+			config.Links = append(config.Links, link)
+			continue
+		}
+		i, ok := groups[group]
+		if !ok {
+			i = len(config.Groups)
+			groups[group] = i
+			config.Groups = append(config.Groups, Group{Name: group})
+		}
+		config.Groups[i].Links = append(config.Groups[i].Links, link)
+	}
+	return config, warnings, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const csvFixture = "testdata/links.csv"
+
+func TestLoadCSVConfig(t *testing.T) {
+	columns, err := parseCSVColumns("name=Title, url=Link, group=Folder, description=Notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := loadConfig(csvFixture, loadOptions{csvColumns: columns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := result.Config
+
+	if len(config.Links) != 1 || !reflect.DeepEqual(config.Links[0], Link{Name: "Router", Url: "http://router.local", Description: "Home router", Tags: []string{"network"}}) {
+		t.Errorf("links = %+v, want the Router link without a group", config.Links)
+	}
+	if got := groupNames(config.Groups); !reflect.DeepEqual(got, []string{"Media", "Monitoring"}) {
+		t.Errorf("groups = %v, want Media, Monitoring in first appearance order", got)
+	}
+	if got := linkNames(config.Groups[0].Links); !reflect.DeepEqual(got, []string{"Jellyfin", "Sonarr, TV"}) {
+		t.Errorf("Media links = %v, want Jellyfin, Sonarr, TV", got)
+	}
+	if got := config.Groups[0].Links[0].Tags; !reflect.DeepEqual(got, []string{"media", "tv"}) {
+		t.Errorf("Jellyfin tags = %v, want media, tv", got)
+	}
+
+	var skipped []string
+	for _, warning := range result.Warnings {
+		if strings.Contains(warning.Message, "skipped") {
+			skipped = append(skipped, warning.Message)
+		}
+	}
+	if len(skipped) != 2 || !strings.HasSuffix(skipped[0], "line 5 skipped: wrong number of fields") || !strings.HasSuffix(skipped[1], "line 6 skipped: missing name or URL") {
+		t.Errorf("warnings = %q, want the short row and the nameless row skipped", skipped)
+	}
+}
+
+func TestDecodeCSVConfigErrors(t *testing.T) {
+	tests := map[string]string{
+		"empty":          "",
+		"no url column":  "name,address\nRouter,http://router.local\n",
+		"no name column": "title,url\nRouter,http://router.local\n",
+	}
+	for name, data := range tests {
+		if _, _, err := decodeCSVConfig([]byte(data), nil); err == nil {
+			t.Errorf("%s: decodeCSVConfig succeeded", name)
+		}
+	}
+}
+
+func TestParseCSVColumns(t *testing.T) {
+	columns, err := parseCSVColumns("name=Title,url = Link,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"name": "Title", "url": "Link"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("columns = %v, want %v", columns, want)
+	}
+	for _, spec := range []string{"name", "name=", "icon=Image"} {
+		if _, err := parseCSVColumns(spec); err == nil {
+			t.Errorf("parseCSVColumns(%q) succeeded", spec)
+		}
+	}
+}
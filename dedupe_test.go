@@ -0,0 +1,86 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDedupeLinks(t *testing.T) {
+	config := Configuration{
+		Links: []Link{
+			{Name: "Router", Url: "http://router.local"},
+			{Name: "NAS", Url: "http://nas.local"},
+			{Name: "Gateway", Url: "http://router.local"},
+		},
+		Groups: []Group{
+			{Name: "Media", Links: []Link{
+				{Name: "Jellyfin", Url: "http://jellyfin.local"},
+				{Name: "Storage", Url: "http://nas.local"},
+				{Name: "Sonarr", Url: "http://sonarr.local"},
+			}},
+			{Name: "Copies", Links: []Link{
+				{Name: "TV", Url: "http://jellyfin.local"},
+			}},
+			{Name: "Empty"},
+		},
+		Pages: map[string]Page{
+			"work": {Links: []Link{
+				{Name: "Router", Url: "http://router.local"},
+				{Name: "Jira", Url: "http://jira.local"},
+				{Name: "Tickets", Url: "http://jira.local"},
+			}},
+		},
+	}
+
+	got, warnings := dedupeLinks(config)
+	if names := linkNames(got.Links); !reflect.DeepEqual(names, []string{"Router", "NAS"}) {
+		t.Errorf("links = %v, want the first occurrences in order", names)
+	}
+	if names := groupNames(got.Groups); !reflect.DeepEqual(names, []string{"Media", "Empty"}) {
+		t.Errorf("groups = %v, want the group left empty by duplicates dropped", names)
+	}
+	if names := linkNames(got.Groups[0].Links); !reflect.DeepEqual(names, []string{"Jellyfin", "Sonarr"}) {
+		t.Errorf("Media links = %v, want Jellyfin, Sonarr", names)
+	}
+	if names := linkNames(got.Pages["work"].Links); !reflect.DeepEqual(names, []string{"Router", "Jira"}) {
+		t.Errorf("work links = %v, want each page deduplicated on its own", names)
+	}
+
+	var dropped []string
+	for _, warning := range warnings {
+		dropped = append(dropped, warning.Link)
+	}
+	if want := []string{"Gateway", "Storage", "TV", "Tickets"}; !reflect.DeepEqual(dropped, want) {
+		t.Errorf("warnings for %v, want %v", dropped, want)
+	}
+	if want := `duplicate of "Router", same URL http://router.local`; warnings[0].Message != want {
+		t.Errorf("warning = %q, want %q", warnings[0].Message, want)
+	}
+
+	if len(config.Links) != 3 || len(config.Groups) != 3 || len(config.Pages["work"].Links) != 3 {
+		t.Error("dedupeLinks modified its argument")
+	}
+}
+
+func TestDedupeMergedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "10-home.yaml", "links:\n  - {name: Router, url: http://router.local}\n  - {name: NAS, url: http://nas.local}\n")
+	writeFile(t, dir, "20-work.yaml", "links:\n  - {name: Gateway, url: http://router.local}\n  - {name: Jira, url: http://jira.local}\n")
+
+	for _, tt := range []struct {
+		dedupe bool
+		want   []string
+	}{
+		{false, []string{"Router", "NAS", "Gateway", "Jira"}},
+		{true, []string{"Router", "NAS", "Jira"}},
+	} {
+		result, err := loadConfig(filepath.Join(dir, "*.yaml"), loadOptions{dedupe: tt.dedupe})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := linkNames(result.Config.Links); !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("dedupe %t: links = %v, want %v", tt.dedupe, names, tt.want)
+		}
+	}
+}
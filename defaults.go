@@ -0,0 +1,34 @@
+package main
+
+import "cmp"
+
+// withDefaults returns link with its empty fields filled in from defaults.
+// The name, URL, alias and key identify a link and aren't taken from
+// defaults. A boolean set in defaults can't be turned off by a link, since
+// false is the same as unset.
+func withDefaults(link, defaults Link) Link {
+	link.Description = cmp.Or(link.Description, defaults.Description)
+	link.Auth = link.Auth || defaults.Auth
+	link.Copyable = link.Copyable || defaults.Copyable
+	link.NewTab = link.NewTab || defaults.NewTab
+	link.Rel = cmp.Or(link.Rel, defaults.Rel)
+	link.Preview = link.Preview || defaults.Preview
+	link.NoAutoDescription = link.NoAutoDescription || defaults.NoAutoDescription
+	link.Class = cmp.Or(link.Class, defaults.Class)
+	link.Icon = cmp.Or(link.Icon, defaults.Icon)
+	if len(link.Tags) == 0 {
+		link.Tags = defaults.Tags
+	}
+	if len(link.ExpectStatus) == 0 {
+		link.ExpectStatus = defaults.ExpectStatus
+	}
+	return link
+}
+
+// applyDefaults fills in every link of config from config.Defaults
+func applyDefaults(config *Configuration) {
+	defaults := config.Defaults
+	*config = mapLinks(*config, func(link Link) Link {
+		return withDefaults(link, defaults)
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDefaultsAndOverrides(t *testing.T) {
+	result, err := loadString(t, `
+defaults:
+  name: Ignored
+  url: "http://ignored.local"
+  alias: ignored
+  description: Home lab service
+  new_tab: true
+  rel: noopener
+  class: lab
+  tags: [lab]
+  expect_status: [200, 401]
+links:
+  - {name: Router, url: "http://router.local"}
+  - name: Wiki
+    url: "http://wiki.local"
+    description: Team notes
+    rel: noreferrer
+    class: docs
+    tags: [docs, team]
+    expect_status: [200]
+groups:
+  - name: Media
+    links:
+      - {name: Jellyfin, url: "http://jellyfin.local", alias: tv}
+`, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := result.Config
+
+	want := []Link{
+		{
+			Name: "Router", Url: "http://router.local",
+			Description: "Home lab service", NewTab: true, Rel: "noopener", Class: "lab",
+			Tags: []string{"lab"}, ExpectStatus: []int{200, 401},
+		},
+		{
+			Name: "Wiki", Url: "http://wiki.local",
+			Description: "Team notes", NewTab: true, Rel: "noreferrer", Class: "docs",
+			Tags: []string{"docs", "team"}, ExpectStatus: []int{200},
+		},
+	}
+	if !reflect.DeepEqual(config.Links, want) {
+		t.Errorf("links =\n%+v\nwant\n%+v", config.Links, want)
+	}
+
+	// Defaults reach grouped links, but never their identity
+	jellyfin := config.Groups[0].Links[0]
+	if jellyfin.Name != "Jellyfin" || jellyfin.Url != "http://jellyfin.local" || jellyfin.Alias != "tv" {
+		t.Errorf("Jellyfin identity = %s %s %s", jellyfin.Name, jellyfin.Url, jellyfin.Alias)
+	}
+	if jellyfin.Description != "Home lab service" || !reflect.DeepEqual(jellyfin.ExpectStatus, []int{200, 401}) {
+		t.Errorf("Jellyfin = %+v, want the defaults", jellyfin)
+	}
+	if _, ok := config.Aliases["ignored"]; ok {
+		t.Error("the default alias was applied")
+	}
+}
+
+func TestDefaultExpectStatus(t *testing.T) {
+	unauthorized := newStatusServer(t, http.StatusUnauthorized).URL
+	result, err := loadString(t, "defaults:\n  expect_status: [401]\nlinks:\n  - {name: Login, url: \""+unauthorized+"\"}\n", loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := checkedHandler(t, result.Config)
+	if status := h.health.status(unauthorized, time.Now()); status != statusUp {
+		t.Errorf("status of a 401 expected by the defaults = %q, want up", status)
+	}
+}
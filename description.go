@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxDescriptionPageSize caps how much of a page is read looking for
+	// its meta description
+	maxDescriptionPageSize = 1 << 20
+	// descriptionWorkers is how many pages are fetched at once
+	descriptionWorkers = 4
+	// descriptionRescan is how often the configuration is scanned for
+	// links whose description is missing or expired
+	descriptionRescan = time.Minute
+)
+
+// description is a fetched meta description. An empty text records a page
+// without one, or a failed fetch, so it isn't refetched until it expires.
+type description struct {
+	text    string
+	fetched time.Time
+}
+
+// descriptionCache fetches the meta description of links that have none in
+// the background. Like favicons, results are kept in memory and, when dir
+// is set, on disk.
+type descriptionCache struct {
+	client  *http.Client
+	limiter *fetchLimiter
+	dir     string
+	// mode is the permission of the files written to dir
+	mode os.FileMode
+	ttl  time.Duration
+	// jitter is the percentage by which each rescan interval is randomly
+	// moved
+	jitter float64
+
+	mu           sync.Mutex
+	descriptions map[string]description // keyed by link URL
+}
+
+func newDescriptionCache(dir string, ttl time.Duration, mode os.FileMode, jitter float64, client *http.Client, limiter *fetchLimiter) (*descriptionCache, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, cacheDirMode(mode)); err != nil {
+			return nil, fmt.Errorf("failed to create description cache dir: %w", err)
+		}
+	}
+	return &descriptionCache{
+		client:       client,
+		limiter:      limiter,
+		dir:          dir,
+		mode:         mode,
+		ttl:          ttl,
+		jitter:       jitter,
+		descriptions: make(map[string]description),
+	}, nil
+}
+
+// wantsDescription reports whether a description is fetched for link
+func wantsDescription(link Link) bool {
+	return link.Description == "" && !link.NoAutoDescription &&
+		(strings.HasPrefix(link.Url, "http://") || strings.HasPrefix(link.Url, "https://"))
+}
+
+// annotate fills in the fetched description of the links that have none.
+// It never fetches, links not fetched yet are left as they are.
+func (c *descriptionCache) annotate(config Configuration) Configuration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return mapLinks(config, func(link Link) Link {
+		if wantsDescription(link) {
+			link.Description = c.descriptions[link.Url].text
+		}
+		return link
+	})
+}
+
+// run fetches the missing descriptions of the handler's configuration
+// right away, then rescans it periodically to pick up reloads and expired
+// entries
+func (c *descriptionCache) run(ctx context.Context, handler *Handler) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		c.refresh(ctx, handler.getConfig())
+		timer.Reset(jitter(descriptionRescan, c.jitter))
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// refresh fetches the descriptions of config that are missing or expired,
+// with at most descriptionWorkers fetches in flight
+func (c *descriptionCache) refresh(ctx context.Context, config Configuration) {
+	now := time.Now()
+	urls := make(chan string)
+	var wg sync.WaitGroup
+	for range descriptionWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rawURL := range urls {
+				c.update(ctx, rawURL)
+			}
+		}()
+	}
+
+	seen := make(map[string]bool)
+	forEachLink(config, func(link Link) {
+		if !wantsDescription(link) || seen[link.Url] {
+			return
+		}
+		seen[link.Url] = true
+		c.mu.Lock()
+		desc, ok := c.descriptions[link.Url]
+		c.mu.Unlock()
+		if !ok || !c.fresh(desc, now) {
+			urls <- link.Url
+		}
+	})
+	close(urls)
+	wg.Wait()
+}
+
+func (c *descriptionCache) fresh(desc description, now time.Time) bool {
+	return now.Sub(desc.fetched) < c.ttl
+}
+
+// update refreshes the description of rawURL from disk or the network
+func (c *descriptionCache) update(ctx context.Context, rawURL string) {
+	desc, ok := c.load(rawURL)
+	persist := false
+	if !ok || !c.fresh(desc, time.Now()) {
+		desc = c.fetch(ctx, rawURL)
+		persist = desc.text != ""
+	}
+
+	c.mu.Lock()
+	c.descriptions[rawURL] = desc
+	c.mu.Unlock()
+
+	if !persist || c.dir == "" {
+		return
+	}
+	if err := writeCacheFile(c.cacheFile(rawURL), []byte(desc.text), c.mode); err != nil {
+		slog.Warn("Failed to write description to cache", "url", rawURL, "error", err)
+	}
+}
+
+// cacheFile returns where the description of rawURL is stored on disk
+func (c *descriptionCache) cacheFile(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".desc")
+}
+
+// load reads the description of rawURL from the disk cache, using the
+// file modification time as the fetch time
+func (c *descriptionCache) load(rawURL string) (description, bool) {
+	if c.dir == "" {
+		return description{}, false
+	}
+	file := c.cacheFile(rawURL)
+	info, err := os.Stat(file)
+	if err != nil {
+		return description{}, false
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return description{}, false
+	}
+	return description{text: string(data), fetched: info.ModTime()}, true
+}
+
+// fetch downloads rawURL and extracts its meta description
+func (c *descriptionCache) fetch(ctx context.Context, rawURL string) description {
+	desc := description{fetched: time.Now()}
+	if err := c.limiter.acquire(ctx); err != nil {
+		return desc
+	}
+	defer c.limiter.release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return desc
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		slog.Debug("Description fetch failed", "url", rawURL, "error", err)
+		return desc
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Debug("Description fetch failed", "url", rawURL, "status", resp.StatusCode)
+		return desc
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" {
+		return desc
+	}
+
+	desc.text = metaDescription(io.LimitReader(resp.Body, maxDescriptionPageSize))
+	return desc
+}
+
+// metaDescription returns the content of the <meta name="description">
+// tag of an HTML document, stopping at the end of its head
+func metaDescription(r io.Reader) string {
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return ""
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) == "body" {
+				return ""
+			}
+			if string(name) != "meta" || !hasAttr {
+				continue
+			}
+			var isDescription bool
+			var content string
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				switch string(key) {
+				case "name":
+					isDescription = strings.EqualFold(string(val), "description")
+				case "content":
+					content = string(val)
+				}
+			}
+			if isDescription {
+				return strings.Join(strings.Fields(content), " ")
+			}
+		}
+	}
+}
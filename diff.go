@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Diff lists the links that differ between two configurations. Links are
+// matched by name, so a renamed link shows up as removed and added.
+type Diff struct {
+	OldHash string       `json:"old_hash"`
+	NewHash string       `json:"new_hash"`
+	Added   []Link       `json:"added"`
+	Removed []Link       `json:"removed"`
+	Changed []LinkChange `json:"changed"`
+}
+
+// LinkChange is a link present in both configurations with different
+// fields
+type LinkChange struct {
+	Name string `json:"name"`
+	Old  Link   `json:"old"`
+	New  Link   `json:"new"`
+}
+
+// linksByName indexes the links of config by name, the last one wins
+// when several share a name
+func linksByName(config Configuration) map[string]Link {
+	links := make(map[string]Link)
+	forEachLink(config, func(link Link) {
+		links[link.Name] = link
+	})
+	return links
+}
+
+// diffConfig compares the links of old and updated. Every list is sorted by
+// name so the result doesn't depend on map iteration.
+func diffConfig(old, updated Configuration) Diff {
+	diff := Diff{
+		OldHash: old.Hash,
+		NewHash: updated.Hash,
+		Added:   []Link{},
+		Removed: []Link{},
+		Changed: []LinkChange{},
+	}
+	oldLinks, newLinks := linksByName(old), linksByName(updated)
+	for name, link := range newLinks {
+		previous, ok := oldLinks[name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, link)
+		case !reflect.DeepEqual(previous, link):
+			diff.Changed = append(diff.Changed, LinkChange{Name: name, Old: previous, New: link})
+		}
+	}
+	for name, link := range oldLinks {
+		if _, ok := newLinks[name]; !ok {
+			diff.Removed = append(diff.Removed, link)
+		}
+	}
+
+	byName := func(a, b Link) int { return strings.Compare(a.Name, b.Name) }
+	slices.SortFunc(diff.Added, byName)
+	slices.SortFunc(diff.Removed, byName)
+	slices.SortFunc(diff.Changed, func(a, b LinkChange) int { return strings.Compare(a.Name, b.Name) })
+	return diff
+}
+
+// diff answers /-/diff with the changes applied by the last reload
+func (h *Handler) diff(w http.ResponseWriter, req *http.Request) {
+	h.mu.RLock()
+	diff := diffConfig(h.previous, h.config)
+	h.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDiffConfig(t *testing.T) {
+	old := Configuration{
+		Hash: "old",
+		Links: []Link{
+			{Name: "Grafana", Url: "http://grafana.local"},
+			{Name: "Wiki", Url: "http://wiki.local"},
+			{Name: "Same", Url: "http://same.local"},
+		},
+		Groups: []Group{{Name: "Media", Links: []Link{{Name: "Plex", Url: "http://plex.local"}}}},
+	}
+	updated := Configuration{
+		Hash: "new",
+		Links: []Link{
+			{Name: "Grafana", Url: "https://grafana.example.com"},
+			{Name: "Same", Url: "http://same.local"},
+			{Name: "Alerts", Url: "http://alerts.local"},
+		},
+		Groups: []Group{{Name: "Media", Links: []Link{{Name: "Jellyfin", Url: "http://jellyfin.local"}, {Name: "Arr", Url: "http://arr.local"}}}},
+	}
+
+	diff := diffConfig(old, updated)
+	if diff.OldHash != "old" || diff.NewHash != "new" {
+		t.Errorf("hashes = %s, %s, want old, new", diff.OldHash, diff.NewHash)
+	}
+	if got := linkNames(diff.Added); !reflect.DeepEqual(got, []string{"Alerts", "Arr", "Jellyfin"}) {
+		t.Errorf("added = %v, want [Alerts Arr Jellyfin]", got)
+	}
+	if got := linkNames(diff.Removed); !reflect.DeepEqual(got, []string{"Plex", "Wiki"}) {
+		t.Errorf("removed = %v, want [Plex Wiki]", got)
+	}
+	want := []LinkChange{{
+		Name: "Grafana",
+		Old:  Link{Name: "Grafana", Url: "http://grafana.local"},
+		New:  Link{Name: "Grafana", Url: "https://grafana.example.com"},
+	}}
+	if !reflect.DeepEqual(diff.Changed, want) {
+		t.Errorf("changed = %+v, want the Grafana URL change", diff.Changed)
+	}
+}
+
+func TestDiffConfigUnchanged(t *testing.T) {
+	config := Configuration{Links: []Link{{Name: "A", Url: "http://a.local"}}}
+	diff := diffConfig(config, config)
+	if len(diff.Added)+len(diff.Removed)+len(diff.Changed) != 0 {
+		t.Errorf("diff of a configuration with itself = %+v, want nothing", diff)
+	}
+
+	// Empty lists are sent as [], not null
+	data, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	json.Unmarshal(data, &doc)
+	for _, key := range []string{"added", "removed", "changed"} {
+		if list, ok := doc[key].([]any); !ok || len(list) != 0 {
+			t.Errorf("%s = %v, want []", key, doc[key])
+		}
+	}
+}
+
+func TestDiffEndpoint(t *testing.T) {
+	h := newTestHandler(t, Configuration{Hash: "1", Links: []Link{{Name: "A", Url: "http://a.local"}}})
+	h.updateConfig(LoadResult{Config: Configuration{Hash: "2", Links: []Link{{Name: "B", Url: "http://b.local"}}}})
+	mux := newTestMux(t, h, AppConfig{ReloadToken: "token"})
+
+	if rec := get(t, mux, "/-/diff"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /-/diff without the token = %d, want 401", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/-/diff", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	var diff Diff
+	if err := json.Unmarshal(rec.Body.Bytes(), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if diff.OldHash != "1" || diff.NewHash != "2" || len(diff.Added) != 1 || len(diff.Removed) != 1 {
+		t.Errorf("GET /-/diff = %+v, want B added and A removed", diff)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build embed_config
+
+package main
+
+import _ "embed"
+
+// embeddedConfig is config.yaml at build time, served when the -config
+// file doesn't exist
+//
+//go:embed config.yaml
+var embeddedConfig []byte
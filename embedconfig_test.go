@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsMissingConfig(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]bool{
+		writeFile(t, dir, "config.yaml", "title: Home\n"): false,
+		filepath.Join(dir, "missing.yaml"):                true,
+		filepath.Join(dir, "*.yaml"):                      false,
+		"https://config.example.com/links.yaml":           false,
+		stdinConfig:                                       false,
+	}
+	for source, want := range tests {
+		if got := isMissingConfig(source); got != want {
+			t.Errorf("isMissingConfig(%s) = %v, want %v", source, got, want)
+		}
+	}
+}
+
+func TestEmbeddedConfig(t *testing.T) {
+	defer func(saved []byte) { embeddedConfig = saved }(embeddedConfig)
+	embeddedConfig = []byte("title: Baked in\nlinks:\n  - {name: Router, url: http://router.local}\n")
+
+	result, err := loadConfig(embeddedConfigSource, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.Title != "Baked in" {
+		t.Errorf("title = %q, want the embedded one", result.Config.Title)
+	}
+	body := renderIndex(t, result.Config)
+	if !strings.Contains(body, "<title>Baked in</title>") || !strings.Contains(body, ">Router</a>") {
+		t.Errorf("the embedded configuration is not served:\n%s", body)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// chromeBookmarks mirrors the layout of Chrome's Bookmarks file
+type chromeBookmarks struct {
+	Roots   chromeRoots `json:"roots"`
+	Version int         `json:"version"`
+}
+
+type chromeRoots struct {
+	BookmarkBar chromeNode `json:"bookmark_bar"`
+	Other       chromeNode `json:"other"`
+	Synced      chromeNode `json:"synced"`
+}
+
+// chromeNode is either a folder or a url entry. Children is a pointer so
+// that folders always carry the key, even when empty, while url entries
+// leave it out.
+type chromeNode struct {
+	Children  *[]chromeNode `json:"children,omitempty"`
+	DateAdded string        `json:"date_added"`
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Type      string        `json:"type"`
+	URL       string        `json:"url,omitempty"`
+}
+
+// chromeEpochOffset is the number of seconds between 1601-01-01, the
+// epoch Chrome uses for bookmark dates, and the Unix epoch
+const chromeEpochOffset = 11644473600
+
+// chromeTimestamp formats t the way Chrome stores dates: microseconds
+// since 1601-01-01 UTC
+func chromeTimestamp(t time.Time) string {
+	return strconv.FormatInt(t.UnixMicro()+chromeEpochOffset*1e6, 10)
+}
+
+// chromeExport builds a Chrome bookmarks document with the top-level links
+// placed in the bookmarks bar, followed by one folder per group
+func chromeExport(config Configuration, now time.Time) chromeBookmarks {
+	added := chromeTimestamp(now)
+	// ids 1 to 3 are taken by the root folders
+	nextID := 3
+	id := func() string {
+		nextID++
+		return strconv.Itoa(nextID)
+	}
+	folder := func(id, name string) chromeNode {
+		return chromeNode{DateAdded: added, ID: id, Name: name, Type: "folder", Children: &[]chromeNode{}}
+	}
+	urls := func(links []Link) []chromeNode {
+		nodes := make([]chromeNode, 0, len(links))
+		for _, link := range links {
+			nodes = append(nodes, chromeNode{DateAdded: added, ID: id(), Name: link.Name, Type: "url", URL: link.Url})
+		}
+		return nodes
+	}
+
+	bar := folder("1", "Bookmarks bar")
+	*bar.Children = urls(config.Links)
+	for _, group := range config.Groups {
+		f := folder(id(), group.Name)
+		*f.Children = urls(group.Links)
+		*bar.Children = append(*bar.Children, f)
+	}
+
+	return chromeBookmarks{
+		Roots: chromeRoots{
+			BookmarkBar: bar,
+			Other:       folder("2", "Other bookmarks"),
+			Synced:      folder("3", "Mobile bookmarks"),
+		},
+		Version: 1,
+	}
+}
+
+func (h *Handler) exportChrome(w http.ResponseWriter, req *http.Request) {
+	config := h.getConfig()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="chrome.json"`)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(chromeExport(config, time.Now())); err != nil {
+		http.Error(w, fmt.Sprintf("Error encoding bookmarks: %v", err), http.StatusInternalServerError)
+	}
+}
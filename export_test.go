@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// checkChromeNode checks node has the keys Chrome expects of a folder or a
+// url entry and records its id in ids
+func checkChromeNode(t *testing.T, node map[string]any, ids map[string]bool) {
+	t.Helper()
+	for _, key := range []string{"date_added", "id", "name", "type"} {
+		if _, ok := node[key].(string); !ok {
+			t.Errorf("node %v has no string %q", node, key)
+		}
+	}
+	id, _ := node["id"].(string)
+	if ids[id] {
+		t.Errorf("id %q is used twice", id)
+	}
+	ids[id] = true
+
+	switch node["type"] {
+	case "folder":
+		children, ok := node["children"].([]any)
+		if !ok {
+			t.Errorf("folder %v has no children array", node["name"])
+		}
+		if _, ok := node["url"]; ok {
+			t.Errorf("folder %v has a url", node["name"])
+		}
+		for _, child := range children {
+			checkChromeNode(t, child.(map[string]any), ids)
+		}
+	case "url":
+		if url, _ := node["url"].(string); url == "" {
+			t.Errorf("url entry %v has no url", node["name"])
+		}
+		if _, ok := node["children"]; ok {
+			t.Errorf("url entry %v has children", node["name"])
+		}
+	default:
+		t.Errorf("node %v has type %v, want folder or url", node["name"], node["type"])
+	}
+}
+
+func TestExportChrome(t *testing.T) {
+	h := newTestHandler(t, Configuration{
+		Links:  []Link{{Name: "Search", Url: "https://search.local"}},
+		Groups: []Group{{Name: "Media", Links: []Link{{Name: "Movies", Url: "http://movies.local"}, {Name: "Music", Url: "http://music.local"}}}},
+	})
+	rec := get(t, http.HandlerFunc(h.exportChrome), "/export/chrome.json")
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["version"] != float64(1) {
+		t.Errorf("version = %v, want 1", doc["version"])
+	}
+	roots, ok := doc["roots"].(map[string]any)
+	if !ok || len(roots) != 3 {
+		t.Fatalf("roots = %v, want bookmark_bar, other and synced", doc["roots"])
+	}
+	ids := make(map[string]bool)
+	for _, name := range []string{"bookmark_bar", "other", "synced"} {
+		root, ok := roots[name].(map[string]any)
+		if !ok || root["type"] != "folder" {
+			t.Fatalf("root %s = %v, want a folder", name, roots[name])
+		}
+		checkChromeNode(t, root, ids)
+	}
+
+	bar := roots["bookmark_bar"].(map[string]any)["children"].([]any)
+	if len(bar) != 2 {
+		t.Fatalf("bookmarks bar has %d entries, want the link and the group folder", len(bar))
+	}
+	if link := bar[0].(map[string]any); link["name"] != "Search" || link["url"] != "https://search.local" {
+		t.Errorf("first entry = %v, want the top-level link", link)
+	}
+	media := bar[1].(map[string]any)
+	if media["name"] != "Media" || len(media["children"].([]any)) != 2 {
+		t.Errorf("second entry = %v, want the Media folder with its two links", media)
+	}
+}
+
+func TestChromeTimestamp(t *testing.T) {
+	if got := chromeTimestamp(time.Unix(0, 0)); got != "11644473600000000" {
+		t.Errorf("chromeTimestamp(Unix epoch) = %s, want 11644473600000000", got)
+	}
+}
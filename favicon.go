@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxFaviconSize caps how much of a favicon response is read
+	maxFaviconSize = 1 << 20
+	// faviconRefreshInterval is the minimum time between two refreshes
+	// through /-/favicons/refresh
+	faviconRefreshInterval = time.Minute
+)
+
+// defaultFavicon is served in place of the icons that couldn't be fetched
+const defaultFavicon = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><circle cx="8" cy="8" r="6" fill="none" stroke="#999" stroke-width="1.5"/></svg>`
+
+// faviconFallbackParam asks /favicons/ for a 404 instead of the default
+// icon when the site has none, so the page moves on in the icon chain
+const faviconFallbackParam = "fallback"
+
+// faviconCSP is the Content-Security-Policy of the icons served from
+// /favicons/. They come from other sites and an SVG one is a document that
+// could otherwise run script on this origin when opened directly.
+const faviconCSP = "default-src 'none'; style-src 'unsafe-inline'; sandbox"
+
+// defaultFaviconURI is defaultFavicon as a data URI, for the page to swap
+// in when an icon fails to load
+var defaultFaviconURI = svgDataURI(defaultFavicon)
+
+// favicon is a fetched icon. A nil data records a failed fetch, so broken
+// sites aren't hammered until the entry expires.
+type favicon struct {
+	data        []byte
+	contentType string
+	fetched     time.Time
+}
+
+// faviconCache fetches /favicon.ico from the hosts of the configured links
+// and keeps them in memory and, when dir is set, on disk so restarts don't
+// refetch everything
+type faviconCache struct {
+	client  *http.Client
+	limiter *fetchLimiter
+	dir     string
+	// mode is the permission of the files written to dir
+	mode os.FileMode
+	ttl  time.Duration
+
+	mu          sync.Mutex
+	icons       map[string]favicon // keyed by host
+	lastRefresh time.Time
+}
+
+// cacheDirMode returns the mode of a cache directory holding files of
+// mode: whoever may read the files may also list the directory
+func cacheDirMode(mode os.FileMode) os.FileMode {
+	return mode | (mode&0o444)>>2
+}
+
+// writeCacheFile writes data to path with exactly mode, whatever the umask
+// and the mode of a previous version
+func writeCacheFile(path string, data []byte, mode os.FileMode) error {
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
+
+func newFaviconCache(dir string, ttl time.Duration, mode os.FileMode, client *http.Client, limiter *fetchLimiter) (*faviconCache, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, cacheDirMode(mode)); err != nil {
+			return nil, fmt.Errorf("failed to create favicon cache dir: %w", err)
+		}
+	}
+	return &faviconCache{
+		client:  client,
+		limiter: limiter,
+		dir:     dir,
+		mode:    mode,
+		ttl:     ttl,
+		icons:   make(map[string]favicon),
+	}, nil
+}
+
+// faviconOrigins maps the host of every link to the origin its favicon is
+// fetched from. Only these hosts are ever fetched, which keeps the
+// favicon route from being used to reach arbitrary servers.
+func faviconOrigins(config Configuration) map[string]string {
+	origins := make(map[string]string)
+	forEachLink(config, func(link Link) {
+		u, err := url.Parse(link.Url)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		if _, ok := origins[u.Host]; !ok {
+			origins[u.Host] = u.Scheme + "://" + u.Host
+		}
+	})
+	return origins
+}
+
+// path returns the route serving the favicon of rawURL, or an empty string
+// when the link has no fetchable host
+func (c *faviconCache) path(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return "/favicons/" + u.Host
+}
+
+// cacheFile returns where the icon of host is stored on disk
+func (c *faviconCache) cacheFile(host string) string {
+	sum := sha256.Sum256([]byte(host))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".ico")
+}
+
+func (c *faviconCache) fresh(icon favicon, now time.Time) bool {
+	return now.Sub(icon.fetched) < c.ttl
+}
+
+// get returns the icon of host, from memory, disk or the network in that
+// order of preference
+func (c *faviconCache) get(ctx context.Context, host, origin string) favicon {
+	now := time.Now()
+	c.mu.Lock()
+	icon, ok := c.icons[host]
+	c.mu.Unlock()
+	if ok && c.fresh(icon, now) {
+		return icon
+	}
+
+	if icon, ok := c.load(host); ok && c.fresh(icon, now) {
+		c.store(host, icon, false)
+		return icon
+	}
+
+	icon = c.fetch(ctx, origin)
+	c.store(host, icon, icon.data != nil)
+	return icon
+}
+
+// load reads the icon of host from the disk cache, using the file
+// modification time as the fetch time
+func (c *faviconCache) load(host string) (favicon, bool) {
+	if c.dir == "" {
+		return favicon{}, false
+	}
+	file := c.cacheFile(host)
+	info, err := os.Stat(file)
+	if err != nil {
+		return favicon{}, false
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return favicon{}, false
+	}
+	contentType, ok := imageType("", data)
+	if !ok {
+		return favicon{}, false
+	}
+	return favicon{data: data, contentType: contentType, fetched: info.ModTime()}, true
+}
+
+// imageType returns the content type to serve an icon with from the
+// Content-Type header it was fetched with and its data. It reports false
+// when the icon isn't an image, typically an HTML error page sent with a
+// 200.
+func imageType(header string, data []byte) (string, bool) {
+	sniffed := http.DetectContentType(data)
+	mediaType, _, _ := mime.ParseMediaType(header)
+	switch {
+	case strings.HasPrefix(mediaType, "image/") && !strings.HasPrefix(sniffed, "text/html"):
+		// Sniffing doesn't know SVG, trust the server unless it's HTML
+		return mediaType, true
+	case strings.HasPrefix(sniffed, "image/"):
+		return sniffed, true
+	case strings.HasPrefix(sniffed, "text/xml") && strings.Contains(string(data), "<svg"):
+		return "image/svg+xml", true
+	}
+	return "", false
+}
+
+// store keeps icon in memory and, when persist is set, on disk
+func (c *faviconCache) store(host string, icon favicon, persist bool) {
+	c.mu.Lock()
+	c.icons[host] = icon
+	c.mu.Unlock()
+
+	if !persist || c.dir == "" {
+		return
+	}
+	if err := writeCacheFile(c.cacheFile(host), icon.data, c.mode); err != nil {
+		slog.Warn("Failed to write favicon to cache", "host", host, "error", err)
+	}
+}
+
+// fetch downloads origin/favicon.ico
+func (c *faviconCache) fetch(ctx context.Context, origin string) favicon {
+	icon := favicon{fetched: time.Now()}
+	if err := c.limiter.acquire(ctx); err != nil {
+		return icon
+	}
+	defer c.limiter.release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/favicon.ico", nil)
+	if err != nil {
+		return icon
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		slog.Debug("Favicon fetch failed", "origin", origin, "error", err)
+		return icon
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Debug("Favicon fetch failed", "origin", origin, "status", resp.StatusCode)
+		return icon
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconSize))
+	if err != nil || len(data) == 0 {
+		return icon
+	}
+	contentType, ok := imageType(resp.Header.Get("Content-Type"), data)
+	if !ok {
+		slog.Debug("Favicon fetch failed: not an image", "origin", origin, "content_type", resp.Header.Get("Content-Type"))
+		return icon
+	}
+	icon.data = data
+	icon.contentType = contentType
+	return icon
+}
+
+// warm fills the cache with the icons of every configured host, using
+// workers goroutines
+func (c *faviconCache) warm(ctx context.Context, config Configuration, workers int) {
+	hosts := make(chan string)
+	origins := faviconOrigins(config)
+	var wg sync.WaitGroup
+	for range max(1, workers) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range hosts {
+				c.get(ctx, host, origins[host])
+			}
+		}()
+	}
+	for host := range origins {
+		hosts <- host
+	}
+	close(hosts)
+	wg.Wait()
+	slog.Debug("Favicon cache warmed")
+}
+
+// refresh drops every cached icon and fetches the icons of config again,
+// returning how many were fetched and how many failed. It refuses to run
+// more often than faviconRefreshInterval and then returns how long to wait.
+func (c *faviconCache) refresh(ctx context.Context, config Configuration) (refreshed, failed int, wait time.Duration) {
+	c.mu.Lock()
+	if wait := faviconRefreshInterval - time.Since(c.lastRefresh); wait > 0 {
+		c.mu.Unlock()
+		return 0, 0, wait
+	}
+	c.lastRefresh = time.Now()
+	c.icons = make(map[string]favicon)
+	c.mu.Unlock()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for host, origin := range faviconOrigins(config) {
+		if c.dir != "" {
+			os.Remove(c.cacheFile(host))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			icon := c.fetch(ctx, origin)
+			c.store(host, icon, icon.data != nil)
+			mu.Lock()
+			defer mu.Unlock()
+			if icon.data != nil {
+				refreshed++
+			} else {
+				failed++
+			}
+		}()
+	}
+	wg.Wait()
+	return refreshed, failed, 0
+}
+
+func (h *Handler) favicon(w http.ResponseWriter, req *http.Request) {
+	host := strings.TrimPrefix(req.URL.Path, "/favicons/")
+	origin, ok := faviconOrigins(h.getConfig())[host]
+	if !ok || h.favicons == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	icon := h.favicons.get(req.Context(), host, origin)
+	w.Header().Set("Content-Security-Policy", faviconCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if icon.data == nil && req.URL.Query().Has(faviconFallbackParam) {
+		w.Header().Set("Cache-Control", "no-cache")
+		http.NotFound(w, req)
+		return
+	}
+	if icon.data == nil {
+		// The site may get an icon, don't let browsers keep the default
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Header().Set("Cache-Control", "no-cache")
+		io.WriteString(w, defaultFavicon)
+		return
+	}
+	w.Header().Set("Content-Type", icon.contentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.favicons.ttl.Seconds())))
+	w.Write(icon.data)
+}
+
+// refreshFavicons answers /-/favicons/refresh with the outcome of
+// refetching every icon
+func (h *Handler) refreshFavicons(w http.ResponseWriter, req *http.Request) {
+	if h.favicons == nil {
+		http.Error(w, "Favicons are disabled", http.StatusNotFound)
+		return
+	}
+	refreshed, failed, wait := h.favicons.refresh(req.Context(), h.getConfig())
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "Favicons were refreshed recently", http.StatusTooManyRequests)
+		return
+	}
+	slog.Info("Favicons refreshed", "refreshed", refreshed, "failed", failed)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"refreshed": refreshed, "failed": failed})
+}
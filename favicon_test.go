@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pngIcon is enough of a PNG file for content sniffing
+var pngIcon = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// newIconServer serves body with contentType at /favicon.ico, counting the
+// requests
+func newIconServer(t *testing.T, contentType string, body []byte) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		if req.URL.Path != "/favicon.ico" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newTestFaviconCache(t *testing.T, dir string, ttl time.Duration, client *http.Client) *faviconCache {
+	t.Helper()
+	c, err := newFaviconCache(dir, ttl, 0o644, client, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestFaviconDiskCache(t *testing.T) {
+	server, requests := newIconServer(t, "image/png", pngIcon)
+	host := strings.TrimPrefix(server.URL, "http://")
+	dir := t.TempDir()
+	ttl := time.Hour
+
+	first := newTestFaviconCache(t, dir, ttl, server.Client())
+	if icon := first.get(context.Background(), host, server.URL); !bytes.Equal(icon.data, pngIcon) {
+		t.Fatalf("first get = %q, want the fetched icon", icon.data)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("%d fetches, want 1", n)
+	}
+	file := first.cacheFile(host)
+	if data, err := os.ReadFile(file); err != nil || !bytes.Equal(data, pngIcon) {
+		t.Fatalf("cache file = %q, %v, want the icon", data, err)
+	}
+
+	// A new cache, as after a restart, serves the icon from disk
+	restarted := newTestFaviconCache(t, dir, ttl, server.Client())
+	icon := restarted.get(context.Background(), host, server.URL)
+	if !bytes.Equal(icon.data, pngIcon) || icon.contentType != "image/png" {
+		t.Errorf("get after restart = %q %q, want the cached PNG", icon.data, icon.contentType)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d fetches after a cache hit, want 1", n)
+	}
+
+	// An entry older than the TTL is fetched again and rewritten
+	stale := time.Now().Add(-2 * ttl)
+	if err := os.Chtimes(file, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	refetched := newTestFaviconCache(t, dir, ttl, server.Client())
+	if icon := refetched.get(context.Background(), host, server.URL); !bytes.Equal(icon.data, pngIcon) {
+		t.Errorf("get of a stale entry = %q, want the icon", icon.data)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("%d fetches after a stale entry, want 2", n)
+	}
+	if info, err := os.Stat(file); err != nil || !info.ModTime().After(stale) {
+		t.Errorf("the stale cache file was not rewritten")
+	}
+}
+
+func TestRefreshFavicons(t *testing.T) {
+	good, goodRequests := newIconServer(t, "image/png", pngIcon)
+	broken, brokenRequests := newIconServer(t, "text/html", []byte("<html>not found</html>"))
+	config := Configuration{Links: []Link{{Name: "Good", Url: good.URL}, {Name: "Broken", Url: broken.URL + "/app"}}}
+	h := newTestHandler(t, config)
+	h.favicons = newTestFaviconCache(t, t.TempDir(), time.Hour, http.DefaultClient)
+	h.favicons.warm(context.Background(), config, 2)
+	if goodRequests.Load() != 1 || brokenRequests.Load() != 1 {
+		t.Fatalf("warm made %d and %d fetches, want 1 each", goodRequests.Load(), brokenRequests.Load())
+	}
+
+	// Replace the cached icon to see the refresh drop it
+	goodHost := strings.TrimPrefix(good.URL, "http://")
+	h.favicons.store(goodHost, favicon{data: []byte("old"), contentType: "image/png", fetched: time.Now()}, true)
+
+	mux := newTestMux(t, h, AppConfig{ReloadToken: "token"})
+	refresh := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/-/favicons/refresh", nil)
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := refresh()
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"failed":1,"refreshed":1}` {
+		t.Fatalf("refresh = %d %s, want 1 refreshed and 1 failed", rec.Code, rec.Body)
+	}
+	if goodRequests.Load() != 2 || brokenRequests.Load() != 2 {
+		t.Errorf("refresh made %d and %d fetches, want every icon fetched again", goodRequests.Load()-1, brokenRequests.Load()-1)
+	}
+	if icon := h.favicons.get(context.Background(), goodHost, good.URL); !bytes.Equal(icon.data, pngIcon) {
+		t.Errorf("icon after refresh = %q, want the fetched one", icon.data)
+	}
+	if data, _ := os.ReadFile(h.favicons.cacheFile(goodHost)); !bytes.Equal(data, pngIcon) {
+		t.Errorf("cache file after refresh = %q, want the fetched icon", data)
+	}
+
+	if rec := refresh(); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("second refresh = %d, want 429 with Retry-After", rec.Code)
+	}
+}
+
+func TestWarmConcurrency(t *testing.T) {
+	server := newConcurrencyServer(t, 20*time.Millisecond)
+	// Every host resolves to the test server, so that each link is a
+	// separate fetch
+	var dialer net.Dialer
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}}
+	var config Configuration
+	for i := range 12 {
+		config.Links = append(config.Links, Link{Name: fmt.Sprint(i), Url: fmt.Sprintf("http://host%d.test/", i)})
+	}
+
+	for _, workers := range []int{1, 3} {
+		server.peak.Store(0)
+		server.total.Store(0)
+		newTestFaviconCache(t, "", time.Hour, client).warm(context.Background(), config, workers)
+		if n := server.total.Load(); n != 12 {
+			t.Errorf("%d workers: %d fetches, want 12", workers, n)
+		}
+		if peak := server.peak.Load(); peak > int32(workers) {
+			t.Errorf("%d workers: %d concurrent fetches", workers, peak)
+		}
+	}
+}
+
+func TestImageType(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		data   string
+		want   string
+		ok     bool
+	}{
+		{"png", "image/png", string(pngIcon), "image/png", true},
+		{"png without a type", "", string(pngIcon), "image/png", true},
+		{"svg", "image/svg+xml", `<svg xmlns="http://www.w3.org/2000/svg"/>`, "image/svg+xml", true},
+		{"svg sent as XML", "text/xml", `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"/>`, "image/svg+xml", true},
+		{"html error page", "text/html; charset=utf-8", "<html><body>Not found</body></html>", "", false},
+		{"html sent as an image", "image/x-icon", "<!DOCTYPE html><html>login</html>", "", false},
+		{"plain text", "text/plain", "not an icon", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := imageType(tt.header, []byte(tt.data))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: imageType = %q %v, want %q %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFaviconRejectsHTML(t *testing.T) {
+	server, _ := newIconServer(t, "image/x-icon", []byte("<!DOCTYPE html><html><body>Sign in</body></html>"))
+	host := strings.TrimPrefix(server.URL, "http://")
+	dir := t.TempDir()
+	h := newTestHandler(t, Configuration{Links: []Link{{Name: "App", Url: server.URL}}})
+	h.favicons = newTestFaviconCache(t, dir, time.Hour, server.Client())
+
+	rec := get(t, http.HandlerFunc(h.favicon), "/favicons/"+host)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" || rec.Body.String() != defaultFavicon {
+		t.Errorf("GET /favicons/%s = %d %q, want the default glyph", host, rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" || rec.Header().Get("Content-Security-Policy") != faviconCSP {
+		t.Errorf("the favicon is served without nosniff and its CSP: %v", rec.Header())
+	}
+	if _, err := os.Stat(h.favicons.cacheFile(host)); !os.IsNotExist(err) {
+		t.Error("the HTML page was written to the disk cache")
+	}
+
+	// The next icon candidate is tried when the page asks for a fallback
+	if rec := get(t, http.HandlerFunc(h.favicon), "/favicons/"+host+"?"+faviconFallbackParam+"=1"); rec.Code != http.StatusNotFound {
+		t.Errorf("fallback request = %d, want 404", rec.Code)
+	}
+}
+
+func TestIconOnError(t *testing.T) {
+	body := renderIndex(t, Configuration{Links: []Link{{Name: "App", Url: "http://app.local", Icon: "http://app.local/icon.png"}}})
+	if !strings.Contains(body, `onerror="nextIcon(this)"`) {
+		t.Error("icons have no onerror fallback")
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"time"
+)
+
+// featuredLink returns the link of the day for date: the links of config
+// take turns, one per calendar day, so the pick is the same all day long.
+// It reports false when config has no links.
+func featuredLink(config Configuration, date time.Time) (Link, bool) {
+	var links []Link
+	forEachLink(config, func(link Link) {
+		links = append(links, link)
+	})
+	if len(links) == 0 {
+		return Link{}, false
+	}
+	year, month, day := date.Date()
+	days := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60)
+	return links[int(days%int64(len(links)))], true
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeaturedLinkStablePerDay(t *testing.T) {
+	config := Configuration{
+		Links:  []Link{{Name: "A", Url: "http://a.local"}, {Name: "B", Url: "http://b.local"}},
+		Groups: []Group{{Name: "G", Links: []Link{{Name: "C", Url: "http://c.local"}}}},
+	}
+	morning := time.Date(2024, 3, 10, 0, 5, 0, 0, time.FixedZone("CET", 3600))
+	first, ok := featuredLink(config, morning)
+	if !ok {
+		t.Fatal("no featured link")
+	}
+	for _, at := range []time.Time{morning.Add(12 * time.Hour), morning.Add(23 * time.Hour)} {
+		if got, _ := featuredLink(config, at); got.Name != first.Name {
+			t.Errorf("featured at %s = %s, want %s as earlier that day", at, got.Name, first.Name)
+		}
+	}
+
+	// Every link gets its turn over consecutive days
+	seen := map[string]bool{}
+	for day := range 3 {
+		link, _ := featuredLink(config, morning.AddDate(0, 0, day))
+		seen[link.Name] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("featured links over 3 days = %v, want all 3 links", seen)
+	}
+	if next, _ := featuredLink(config, morning.AddDate(0, 0, 1)); next.Name == first.Name {
+		t.Error("the featured link didn't change the next day")
+	}
+}
+
+func TestFeaturedLinkEmpty(t *testing.T) {
+	if _, ok := featuredLink(Configuration{}, time.Now()); ok {
+		t.Error("a configuration without links has a featured link")
+	}
+}
+
+func TestFeaturedRendering(t *testing.T) {
+	config := Configuration{Links: []Link{{Name: "A", Url: "http://a.local"}}}
+	if body := renderIndex(t, config); strings.Contains(body, "Link of the day") {
+		t.Error("the link of the day is shown without -featured")
+	}
+	h := newTestHandler(t, config)
+	h.featured = true
+	if body := get(t, http.HandlerFunc(h.index), "/").Body.String(); !strings.Contains(body, `<h2 id="featured">Link of the day</h2>`) {
+		t.Error("the link of the day is missing with -featured")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// fetchTimeouts bound every step of an outbound request so a slow or
+// unresponsive site can't hold a fetcher forever
+type fetchTimeouts struct {
+	// total bounds the whole request, body included
+	total time.Duration
+	// dial bounds establishing the TCP connection
+	dial time.Duration
+	// tls bounds the TLS handshake
+	tls time.Duration
+	// header bounds the wait for the response headers once the request is
+	// sent
+	header time.Duration
+}
+
+// newFetchClient returns the client shared by the outbound fetchers, the
+// config loader, link checks, favicons, descriptions and sources
+func newFetchClient(timeouts fetchTimeouts) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   timeouts.dial,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = timeouts.tls
+	transport.ResponseHeaderTimeout = timeouts.header
+	return &http.Client{Timeout: timeouts.total, Transport: transport}
+}
+
+// fetchLimiter caps the number of outbound requests in flight across the
+// health checker, favicon and description fetchers, so startup doesn't
+// open a connection to every link at once. A nil limiter doesn't limit.
+type fetchLimiter struct {
+	slots chan struct{}
+}
+
+// newFetchLimiter returns a limiter allowing n requests at once, or nil
+// when n is not positive
+func newFetchLimiter(n int) *fetchLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return &fetchLimiter{slots: make(chan struct{}, n)}
+}
+
+// acquire waits for a free slot, it fails only when ctx is done first
+func (l *fetchLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire
+func (l *fetchLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
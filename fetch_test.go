@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrencyServer records the largest number of requests it served at
+// once, each one taking delay
+type concurrencyServer struct {
+	*httptest.Server
+	inFlight atomic.Int32
+	peak     atomic.Int32
+	total    atomic.Int32
+}
+
+func newConcurrencyServer(t *testing.T, delay time.Duration) *concurrencyServer {
+	t.Helper()
+	s := &concurrencyServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.total.Add(1)
+		n := s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		for {
+			peak := s.peak.Load()
+			if n <= peak || s.peak.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngIcon)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestFetchLimiterCap(t *testing.T) {
+	limiter := newFetchLimiter(3)
+	var inFlight, peak atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limiter.acquire(context.Background()); err != nil {
+				t.Error(err)
+				return
+			}
+			defer limiter.release()
+			n := inFlight.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			inFlight.Add(-1)
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p > 3 {
+		t.Errorf("%d holders at once, want at most 3", p)
+	}
+}
+
+func TestFetchLimiterAcquireCancelled(t *testing.T) {
+	limiter := newFetchLimiter(1)
+	if err := limiter.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.acquire(ctx); err == nil {
+		t.Error("acquire on a full limiter succeeded")
+	}
+}
+
+func TestNilFetchLimiter(t *testing.T) {
+	limiter := newFetchLimiter(0)
+	if limiter != nil {
+		t.Fatalf("newFetchLimiter(0) = %v, want nil", limiter)
+	}
+	for range 10 {
+		if err := limiter.acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	limiter.release()
+}
+
+func TestFetchLimiterSharedByFetchers(t *testing.T) {
+	server := newConcurrencyServer(t, 10*time.Millisecond)
+	var config Configuration
+	for i := range 10 {
+		config.Links = append(config.Links, Link{Name: fmt.Sprint(i), Url: fmt.Sprintf("%s/%d", server.URL, i)})
+	}
+	limiter := newFetchLimiter(2)
+	health := newHealthChecker(time.Hour, 0, 1, 0, server.Client(), limiter)
+	descriptions, err := newDescriptionCache("", time.Hour, 0o644, 0, server.Client(), limiter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		health.checkAll(context.Background(), config)
+	}()
+	go func() {
+		defer wg.Done()
+		descriptions.refresh(context.Background(), config)
+	}()
+	wg.Wait()
+
+	if total := server.total.Load(); total < 20 {
+		t.Fatalf("the server got %d requests, want one per link and fetcher", total)
+	}
+	if peak := server.peak.Load(); peak > 2 {
+		t.Errorf("%d requests at once across the fetchers, want at most 2", peak)
+	}
+}
+
+// newSlowServer sends the headers after headerDelay, then the body after
+// bodyDelay, giving up when the client goes away
+func newSlowServer(t *testing.T, headerDelay, bodyDelay time.Duration) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		wait := func(d time.Duration) {
+			select {
+			case <-time.After(d):
+			case <-req.Context().Done():
+			}
+		}
+		wait(headerDelay)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		wait(bodyDelay)
+		w.Write([]byte("done"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchClientTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts fetchTimeouts
+		header   time.Duration
+		body     time.Duration
+	}{
+		{"response header", fetchTimeouts{header: 50 * time.Millisecond}, 5 * time.Second, 0},
+		{"total", fetchTimeouts{total: 100 * time.Millisecond}, 0, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSlowServer(t, tt.header, tt.body)
+			client := newFetchClient(tt.timeouts)
+			start := time.Now()
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				_, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			if err == nil {
+				t.Fatal("the request did not time out")
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("the request took %s to time out", elapsed)
+			}
+		})
+	}
+}
+
+func TestLinkCheckTimeout(t *testing.T) {
+	server := newSlowServer(t, 5*time.Second, 0)
+	config := Configuration{Links: []Link{{Name: "Slow", Url: server.URL}}}
+	checker := newHealthChecker(time.Hour, 0, 1, 0, newFetchClient(fetchTimeouts{total: 100 * time.Millisecond}), nil)
+	start := time.Now()
+	checker.checkAll(context.Background(), config)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the check took %s", elapsed)
+	}
+	if status := checker.status(server.URL, time.Now()); status != statusDown {
+		t.Errorf("status of a link past the timeout = %q, want %q", status, statusDown)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileMode(t *testing.T) {
+	server, _ := newIconServer(t, "image/png", pngIcon)
+	host := strings.TrimPrefix(server.URL, "http://")
+	for _, mode := range []os.FileMode{0o600, 0o640, 0o644, 0o666} {
+		t.Run(mode.String(), func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "cache")
+			c, err := newFaviconCache(dir, time.Hour, mode, server.Client(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if icon := c.get(context.Background(), host, server.URL); !bytes.Equal(icon.data, pngIcon) {
+				t.Fatalf("get = %q, want the fetched icon", icon.data)
+			}
+			info, err := os.Stat(c.cacheFile(host))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != mode {
+				t.Errorf("cache file mode = %v, want %v", got, mode)
+			}
+			info, err = os.Stat(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The directory is created under the umask, it is never more open
+			if got, want := info.Mode().Perm(), cacheDirMode(mode); got&^want != 0 {
+				t.Errorf("cache dir mode = %v, want at most %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCacheDirMode(t *testing.T) {
+	tests := map[os.FileMode]os.FileMode{
+		0o600: 0o700,
+		0o640: 0o750,
+		0o644: 0o755,
+	}
+	for mode, want := range tests {
+		if got := cacheDirMode(mode); got != want {
+			t.Errorf("cacheDirMode(%v) = %v, want %v", mode, got, want)
+		}
+	}
+}
+
+func TestWriteCacheFileMode(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "icon.ico", "old")
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCacheFile(path, pngIcon, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0o600 {
+		t.Errorf("rewritten file mode = %v, want -rw-------", got)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration file formats accepted by -config-format
+const (
+	formatYAML = "yaml"
+	formatJSON = "json"
+	formatText = "text"
+	formatCSV  = "csv"
+)
+
+var configFormats = []string{formatYAML, formatJSON, formatText, formatCSV}
+
+// configFormat returns the format source is read as: override when it is
+// set, else the one its extension tells, YAML for anything unknown
+func configFormat(source, override string) string {
+	if override != "" {
+		return override
+	}
+	switch strings.ToLower(filepath.Ext(source)) {
+	case ".json":
+		return formatJSON
+	case ".txt":
+		return formatText
+	case ".csv":
+		return formatCSV
+	}
+	return formatYAML
+}
+
+// decodeConfig parses a configuration file in format. JSON is a subset of
+// YAML so both go through the YAML decoder and share its field names, JSON
+// files are only checked to be valid JSON first. Only CSV files can have
+// warnings, for the rows they skip.
+func decodeConfig(data []byte, format string, opts loadOptions) (Configuration, []Warning, error) {
+	switch format {
+	case formatText:
+		config, err := decodeTextConfig(data)
+		return config, nil, err
+	case formatCSV:
+		return decodeCSVConfig(data, opts.csvColumns)
+	}
+	var config Configuration
+	if format == formatJSON {
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return config, nil, err
+		}
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, nil, err
+	}
+	return config, nil, nil
+}
+
+// decodeTextConfig parses the plain text format: one link per line, either
+// "Name = URL" or a bare URL named after its host. Blank lines and lines
+// starting with # are skipped.
+func decodeTextConfig(data []byte) (Configuration, error) {
+	var config Configuration
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		link, err := parseTextLink(line)
+		if err != nil {
+			return Configuration{}, fmt.Errorf("line %d: %w", n, err)
+		}
+		config.Links = append(config.Links, link)
+	}
+	return config, scanner.Err()
+}
+
+// parseTextLink parses a line of the plain text format. A line is a bare
+// URL when its scheme comes before any '=', which only query strings then
+// contain.
+func parseTextLink(line string) (Link, error) {
+	name, rawURL, named := strings.Cut(line, "=")
+	if scheme := strings.Index(line, "://"); scheme >= 0 && scheme < len(name) {
+		named, rawURL = false, line
+	}
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" {
+		return Link{}, fmt.Errorf("invalid URL %q", rawURL)
+	}
+	if !named {
+		name = u.Hostname()
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Link{}, fmt.Errorf("no name for %q", rawURL)
+	}
+	return Link{Name: name, Url: rawURL}, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigFormat(t *testing.T) {
+	tests := []struct {
+		source, override, want string
+	}{
+		{"/config/links", "", formatYAML},
+		{"config.yaml", "", formatYAML},
+		{"config.JSON", "", formatJSON},
+		{"links.txt", "", formatText},
+		{"links.csv", "", formatCSV},
+		{"/config/links", formatJSON, formatJSON},
+		{"config.yaml", formatText, formatText},
+	}
+	for _, tt := range tests {
+		if got := configFormat(tt.source, tt.override); got != tt.want {
+			t.Errorf("configFormat(%q, %q) = %q, want %q", tt.source, tt.override, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigWithoutExtension(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		format  string
+	}{
+		{"yaml detected", "links:\n  - {name: Router, url: http://router.local}\n", ""},
+		{"yaml forced", "links:\n  - {name: Router, url: http://router.local}\n", formatYAML},
+		{"json forced", `{"links": [{"name": "Router", "url": "http://router.local"}]}`, formatJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := loadConfig(writeFile(t, dir, "links", tt.content), loadOptions{format: tt.format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := linkNames(result.Config.Links); !reflect.DeepEqual(got, []string{"Router"}) {
+				t.Errorf("links = %v, want [Router]", got)
+			}
+		})
+	}
+
+	// The override wins over the extension too
+	result, err := loadConfig(writeFile(t, dir, "config.yaml", "Router = http://router.local\n"), loadOptions{format: formatText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := linkNames(result.Config.Links); !reflect.DeepEqual(got, []string{"Router"}) {
+		t.Errorf("links of a text file named .yaml = %v, want [Router]", got)
+	}
+	if _, err := loadConfig(writeFile(t, dir, "config.yaml", "title: Home\n"), loadOptions{format: formatJSON}); err == nil {
+		t.Error("YAML loaded with -config-format json")
+	}
+}
+
+func TestDecodeTextConfig(t *testing.T) {
+	data := `# Quick links
+Router = http://router.local
+https://grafana.example.com/d/home
+
+Search = https://search.local/?q=a=b
+  Wiki=http://wiki.local  
+http://nas.local:5000/?tab=files
+`
+	config, err := decodeTextConfig([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Link{
+		{Name: "Router", Url: "http://router.local"},
+		{Name: "grafana.example.com", Url: "https://grafana.example.com/d/home"},
+		{Name: "Search", Url: "https://search.local/?q=a=b"},
+		{Name: "Wiki", Url: "http://wiki.local"},
+		{Name: "nas.local", Url: "http://nas.local:5000/?tab=files"},
+	}
+	if !reflect.DeepEqual(config.Links, want) {
+		t.Errorf("links = %+v, want %+v", config.Links, want)
+	}
+}
+
+func TestDecodeTextConfigErrors(t *testing.T) {
+	tests := map[string]string{
+		"no scheme":  "Router = router.local\n",
+		"no name":    "http://ok.local\n = http://nameless.local\n",
+		"not a link": "just some words\n",
+	}
+	for name, data := range tests {
+		if _, err := decodeTextConfig([]byte(data)); err == nil {
+			t.Errorf("%s: decodeTextConfig succeeded", name)
+		}
+	}
+	if _, err := decodeTextConfig([]byte("Router\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("error = %v, want it to name the line", err)
+	}
+}
+
+func TestLoadTextConfig(t *testing.T) {
+	result, err := loadConfig(writeFile(t, t.TempDir(), "links.txt", "Router = http://router.local\nhttp://nas.local\n"), loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := linkNames(result.Config.Links); !reflect.DeepEqual(got, []string{"Router", "nas.local"}) {
+		t.Errorf("links = %v, want [Router nas.local]", got)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"slices"
+)
+
+// defaultGroupName collects the links no auto-group rule matched
+const defaultGroupName = "Other"
+
+// matchAutoGroup returns the group of the first rule matching the host of
+// rawURL, or defaultGroupName when none does
+func matchAutoGroup(rules []AutoGroupRule, rawURL string) string {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	for _, rule := range rules {
+		// Patterns are validated in applyAutoGroups, so errors can't happen here
+		if ok, _ := path.Match(rule.Pattern, host); ok {
+			return rule.Group
+		}
+	}
+	return defaultGroupName
+}
+
+// applyAutoGroups moves the top-level links of config into groups according
+// to config.AutoGroups. Links join an existing group of the same name when
+// there is one; otherwise groups are created in the order they're first
+// needed.
+func applyAutoGroups(config *Configuration) error {
+	if len(config.AutoGroups) == 0 {
+		return nil
+	}
+	for _, rule := range config.AutoGroups {
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid auto group pattern %q: %w", rule.Pattern, err)
+		}
+	}
+
+	index := make(map[string]int, len(config.Groups))
+	for i, group := range config.Groups {
+		index[group.Name] = i
+	}
+	for _, link := range config.Links {
+		name := matchAutoGroup(config.AutoGroups, link.Url)
+		i, ok := index[name]
+		if !ok {
+			i = len(config.Groups)
+			index[name] = i
+			config.Groups = append(config.Groups, Group{Name: name})
+		}
+		config.Groups[i].Links = append(config.Groups[i].Links, link)
+	}
+	config.Links = nil
+	return nil
+}
+
+// orderGroups returns groups sorted by their position in order. Groups
+// not listed there follow, sorted by name. An empty order keeps the
+// groups as they are.
+func orderGroups(groups []Group, order []string) []Group {
+	if len(order) == 0 {
+		return groups
+	}
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		if _, ok := rank[name]; !ok {
+			rank[name] = i
+		}
+	}
+
+	sorted := slices.Clone(groups)
+	slices.SortStableFunc(sorted, func(a, b Group) int {
+		ra, aListed := rank[a.Name]
+		rb, bListed := rank[b.Name]
+		switch {
+		case aListed && bListed:
+			return cmp.Compare(ra, rb)
+		case aListed:
+			return -1
+		case bListed:
+			return 1
+		default:
+			return cmp.Compare(a.Name, b.Name)
+		}
+	})
+	return sorted
+}
+
+// untaggedGroupName collects the links without tags in the tag view
+const untaggedGroupName = "untagged"
+
+// groupByTag returns config with its links, grouped or not, sorted into one
+// group per tag, in alphabetical order, then an untagged group. A link with
+// several tags shows up in each of their groups.
+func groupByTag(config Configuration) Configuration {
+	byTag := make(map[string][]Link)
+	var untagged []Link
+	add := func(link Link) {
+		if len(link.Tags) == 0 {
+			untagged = append(untagged, link)
+			return
+		}
+		for _, tag := range slices.Compact(slices.Sorted(slices.Values(link.Tags))) {
+			byTag[tag] = append(byTag[tag], link)
+		}
+	}
+	for _, link := range config.Links {
+		add(link)
+	}
+	for _, group := range config.Groups {
+		for _, link := range group.Links {
+			add(link)
+		}
+	}
+
+	config.Links = nil
+	config.Groups = nil
+	for _, tag := range slices.Sorted(maps.Keys(byTag)) {
+		config.Groups = append(config.Groups, Group{Name: tag, Links: byTag[tag]})
+	}
+	if len(untagged) > 0 {
+		config.Groups = append(config.Groups, Group{Name: untaggedGroupName, Links: untagged})
+	}
+	return config
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// groupNames returns the names of groups, in order
+func groupNames(groups []Group) []string {
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
+
+// linkNames returns the names of links, in order
+func linkNames(links []Link) []string {
+	names := make([]string, 0, len(links))
+	for _, link := range links {
+		names = append(names, link.Name)
+	}
+	return names
+}
+
+func TestMatchAutoGroup(t *testing.T) {
+	rules := []AutoGroupRule{
+		{Pattern: "grafana.*", Group: "Monitoring"},
+		{Pattern: "*.grafana.*", Group: "Monitoring"},
+		{Pattern: "media.home.lan", Group: "Media"},
+		// Never reached for media.home.lan, the rule above comes first
+		{Pattern: "*.home.lan", Group: "Home"},
+	}
+	tests := []struct {
+		url, want string
+	}{
+		{"https://grafana.example.com/d/1", "Monitoring"},
+		{"https://ops.grafana.net", "Monitoring"},
+		{"http://media.home.lan:8096", "Media"},
+		{"http://router.home.lan", "Home"},
+		{"https://search.example.com", defaultGroupName},
+		{"not a url", defaultGroupName},
+	}
+	for _, tt := range tests {
+		if got := matchAutoGroup(rules, tt.url); got != tt.want {
+			t.Errorf("matchAutoGroup(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestApplyAutoGroups(t *testing.T) {
+	config := Configuration{
+		Links: []Link{
+			{Name: "Search", Url: "https://search.example.com"},
+			{Name: "Grafana", Url: "https://grafana.example.com"},
+			{Name: "Jellyfin", Url: "http://media.home.lan"},
+			{Name: "Prometheus", Url: "https://prometheus.example.com"},
+		},
+		Groups: []Group{{Name: "Media", Links: []Link{{Name: "Plex", Url: "http://plex.local"}}}},
+		AutoGroups: []AutoGroupRule{
+			{Pattern: "grafana.*", Group: "Monitoring"},
+			{Pattern: "prometheus.*", Group: "Monitoring"},
+			{Pattern: "media.*", Group: "Media"},
+		},
+	}
+	if err := applyAutoGroups(&config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Links) != 0 {
+		t.Errorf("%d links left outside groups, want none", len(config.Links))
+	}
+	want := map[string][]string{
+		"Media":          {"Plex", "Jellyfin"},
+		defaultGroupName: {"Search"},
+		"Monitoring":     {"Grafana", "Prometheus"},
+	}
+	if names := groupNames(config.Groups); !reflect.DeepEqual(names, []string{"Media", defaultGroupName, "Monitoring"}) {
+		t.Errorf("groups = %v, want the existing one then the new ones as first needed", names)
+	}
+	for _, group := range config.Groups {
+		if got := linkNames(group.Links); !reflect.DeepEqual(got, want[group.Name]) {
+			t.Errorf("group %s has %v, want %v", group.Name, got, want[group.Name])
+		}
+	}
+}
+
+func TestApplyAutoGroupsInvalidPattern(t *testing.T) {
+	config := Configuration{Links: []Link{{Name: "A", Url: "http://a.local"}}, AutoGroups: []AutoGroupRule{{Pattern: "[", Group: "Broken"}}}
+	if err := applyAutoGroups(&config); err == nil {
+		t.Error("applyAutoGroups accepted the pattern \"[\"")
+	}
+}
+
+func TestOrderGroups(t *testing.T) {
+	groups := []Group{{Name: "Work"}, {Name: "Media"}, {Name: "Dev"}, {Name: "Home"}, {Name: "Admin"}}
+	tests := []struct {
+		name  string
+		order []string
+		want  []string
+	}{
+		{"no order", nil, []string{"Work", "Media", "Dev", "Home", "Admin"}},
+		{"all listed", []string{"Admin", "Dev", "Home", "Media", "Work"}, []string{"Admin", "Dev", "Home", "Media", "Work"}},
+		{"unlisted appended alphabetically", []string{"Home", "Work"}, []string{"Home", "Work", "Admin", "Dev", "Media"}},
+		{"unknown and repeated names", []string{"Missing", "Media", "Home", "Media"}, []string{"Media", "Home", "Admin", "Dev", "Work"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := groupNames(orderGroups(groups, tt.order)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderGroups = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := groupNames(groups); !reflect.DeepEqual(got, []string{"Work", "Media", "Dev", "Home", "Admin"}) {
+		t.Errorf("orderGroups changed its argument to %v", got)
+	}
+}
+
+func TestGroupOrderIsRendered(t *testing.T) {
+	body := renderIndex(t, Configuration{
+		Groups: []Group{
+			{Name: "Work", Links: []Link{{Name: "W", Url: "http://w.local"}}},
+			{Name: "Media", Links: []Link{{Name: "M", Url: "http://m.local"}}},
+			{Name: "Dev", Links: []Link{{Name: "D", Url: "http://d.local"}}},
+		},
+		GroupOrder: []string{"Media"},
+	})
+	media, dev, work := strings.Index(body, ">Media<"), strings.Index(body, ">Dev<"), strings.Index(body, ">Work<")
+	if media < 0 || dev < 0 || work < 0 || !(media < dev && dev < work) {
+		t.Errorf("groups rendered at %d, %d, %d, want Media, Dev then Work", media, dev, work)
+	}
+}
+
+func TestGroupByTag(t *testing.T) {
+	config := Configuration{
+		Links: []Link{
+			{Name: "Grafana", Url: "http://grafana.local", Tags: []string{"ops", "dashboards"}},
+			{Name: "Router", Url: "http://router.local"},
+		},
+		Groups: []Group{{Name: "Media", Links: []Link{
+			{Name: "Jellyfin", Url: "http://jellyfin.local", Tags: []string{"media", "ops", "ops"}},
+			{Name: "Notes", Url: "http://notes.local"},
+		}}},
+	}
+	grouped := groupByTag(config)
+	if len(grouped.Links) != 0 {
+		t.Errorf("top-level links = %v, want none", linkNames(grouped.Links))
+	}
+	got := map[string][]string{}
+	for _, group := range grouped.Groups {
+		got[group.Name] = linkNames(group.Links)
+	}
+	want := map[string][]string{
+		"dashboards":      {"Grafana"},
+		"media":           {"Jellyfin"},
+		"ops":             {"Grafana", "Jellyfin"},
+		untaggedGroupName: {"Router", "Notes"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groups = %v, want %v", got, want)
+	}
+	if names := groupNames(grouped.Groups); !reflect.DeepEqual(names, []string{"dashboards", "media", "ops", untaggedGroupName}) {
+		t.Errorf("group order = %v, want the tags sorted then %s", names, untaggedGroupName)
+	}
+	if len(config.Groups) != 1 || config.Groups[0].Name != "Media" {
+		t.Error("groupByTag changed its argument")
+	}
+}
+
+func TestGroupByTagView(t *testing.T) {
+	h := newTestHandler(t, Configuration{Links: []Link{
+		{Name: "Grafana", Url: "http://grafana.local", Tags: []string{"ops", "dashboards"}},
+	}})
+	body := get(t, http.HandlerFunc(h.index), "/?group=tag").Body.String()
+	if strings.Count(body, ">Grafana</a>") != 2 {
+		t.Errorf("Grafana is not shown under both of its tags")
+	}
+	for _, tag := range []string{"ops", "dashboards"} {
+		if !strings.Contains(body, ">"+tag+"</h2>") {
+			t.Errorf("no %s section", tag)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// HeaderRule shows another set of links to the requests carrying a header,
+// such as a kiosk sending X-Kiosk: lobby. It either serves a page at / or
+// narrows the page to some of its groups.
+type HeaderRule struct {
+	Header string `yaml:"header"`
+	// Value is the header value to match, any value matches when empty
+	Value string `yaml:"value,omitempty"`
+	// Page is served in place of the main page
+	Page string `yaml:"page,omitempty"`
+	// Groups are the only groups shown, without the top-level links
+	Groups []string `yaml:"groups,omitempty"`
+}
+
+// checkHeaderRules rejects the rules that can't match or point nowhere
+func checkHeaderRules(config Configuration) error {
+	for _, rule := range config.HeaderRules {
+		if rule.Header == "" {
+			return errors.New("header rule without a header")
+		}
+		if rule.Page == "" && len(rule.Groups) == 0 {
+			return fmt.Errorf("header rule on %s sets neither a page nor groups", rule.Header)
+		}
+		if _, ok := config.Pages[rule.Page]; rule.Page != "" && !ok {
+			return fmt.Errorf("header rule on %s: unknown page %q", rule.Header, rule.Page)
+		}
+	}
+	return nil
+}
+
+// matchHeaderRule returns the index of the first rule matching header, or
+// -1 when none does
+func matchHeaderRule(rules []HeaderRule, header http.Header) int {
+	for i, rule := range rules {
+		values := header.Values(rule.Header)
+		if len(values) > 0 && (rule.Value == "" || slices.Contains(values, rule.Value)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// filterGroups returns view with only the groups rule lists
+func (rule HeaderRule) filterGroups(view Configuration) Configuration {
+	if len(rule.Groups) == 0 {
+		return view
+	}
+	view.Links = nil
+	view.Groups = slices.DeleteFunc(slices.Clone(view.Groups), func(group Group) bool {
+		return !slices.Contains(rule.Groups, group.Name)
+	})
+	return view
+}
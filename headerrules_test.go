@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const headerRulesConfig = `
+title: Home
+links:
+  - {name: Router, url: "http://router.local"}
+groups:
+  - name: Lobby
+    links:
+      - {name: Menu, url: "http://menu.local"}
+  - name: Admin
+    links:
+      - {name: Grafana, url: "http://grafana.local"}
+pages:
+  kitchen:
+    title: Kitchen
+    links:
+      - {name: Recipes, url: "http://recipes.local"}
+header_rules:
+  - {header: X-Kiosk, value: lobby, groups: [Lobby]}
+  - {header: X-Kiosk, value: kitchen, page: kitchen}
+`
+
+func TestHeaderRules(t *testing.T) {
+	result, err := loadString(t, headerRulesConfig, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandler(t, result.Config)
+
+	tests := []struct {
+		name    string
+		kiosk   string
+		want    []string
+		notWant []string
+	}{
+		{name: "no header", want: []string{"Router", "Menu", "Grafana"}, notWant: []string{"Recipes"}},
+		{name: "lobby groups", kiosk: "lobby", want: []string{"Menu"}, notWant: []string{"Router", "Grafana"}},
+		{name: "kitchen page", kiosk: "kitchen", want: []string{"Recipes"}, notWant: []string{"Router", "Menu"}},
+		{name: "unknown value", kiosk: "garage", want: []string{"Router", "Menu", "Grafana"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.kiosk != "" {
+				req.Header.Set("X-Kiosk", tt.kiosk)
+			}
+			rec := httptest.NewRecorder()
+			h.index(rec, req)
+			body := rec.Body.String()
+			for _, name := range tt.want {
+				if !strings.Contains(body, ">"+name+"</a>") {
+					t.Errorf("%s is missing", name)
+				}
+			}
+			for _, name := range tt.notWant {
+				if strings.Contains(body, ">"+name+"</a>") {
+					t.Errorf("%s is shown", name)
+				}
+			}
+			if !strings.Contains(rec.Header().Get("Vary"), "X-Kiosk") {
+				t.Errorf("Vary = %q, want X-Kiosk", rec.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestMatchHeaderRule(t *testing.T) {
+	rules := []HeaderRule{{Header: "X-Kiosk", Value: "lobby"}, {Header: "X-Display"}}
+	tests := []struct {
+		header http.Header
+		want   int
+	}{
+		{http.Header{}, -1},
+		{http.Header{"X-Kiosk": {"lobby"}}, 0},
+		{http.Header{"X-Kiosk": {"other"}}, -1},
+		{http.Header{"X-Kiosk": {"other"}, "X-Display": {"any"}}, 1},
+	}
+	for _, tt := range tests {
+		if got := matchHeaderRule(rules, tt.header); got != tt.want {
+			t.Errorf("matchHeaderRule(%v) = %d, want %d", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidHeaderRules(t *testing.T) {
+	tests := map[string]string{
+		"no header":    "header_rules:\n  - {value: lobby, groups: [Lobby]}\n",
+		"no target":    "header_rules:\n  - {header: X-Kiosk}\n",
+		"unknown page": "header_rules:\n  - {header: X-Kiosk, page: missing}\n",
+	}
+	for name, content := range tests {
+		if _, err := loadString(t, content, loadOptions{}); err == nil {
+			t.Errorf("%s: the rule was accepted", name)
+		}
+	}
+}
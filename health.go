@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Link statuses shown on the page
+const (
+	statusUp    = "up"
+	statusDown  = "down"
+	statusStale = "stale"
+)
+
+// linkHealth is the outcome of the last check of a link
+type linkHealth struct {
+	Up      bool      `json:"up"`
+	Code    int       `json:"code,omitempty"`
+	Err     string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked"`
+	// FrameBlocked is set when the response headers forbid embedding the
+	// page in a frame on another site
+	FrameBlocked bool `json:"frame_blocked,omitempty"`
+}
+
+// healthChecker periodically probes every link with a HEAD request
+type healthChecker struct {
+	client   *http.Client
+	limiter  *fetchLimiter
+	interval time.Duration
+	// jitter is the percentage by which each interval is randomly moved
+	jitter float64
+	// stale is how old a result may get before it's no longer trusted
+	stale time.Duration
+	// threshold is the fraction of links that must be up for
+	// /healthz?links=1 to succeed
+	threshold float64
+
+	mu     sync.RWMutex
+	health map[string]linkHealth // keyed by URL
+}
+
+func newHealthChecker(interval, stale time.Duration, threshold, jitter float64, client *http.Client, limiter *fetchLimiter) *healthChecker {
+	if stale <= 0 {
+		stale = 3 * interval
+	}
+	// A redirect answer already tells the service is up
+	noRedirects := *client
+	noRedirects.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &healthChecker{
+		client:    &noRedirects,
+		limiter:   limiter,
+		interval:  interval,
+		jitter:    jitter,
+		stale:     stale,
+		threshold: threshold,
+		health:    make(map[string]linkHealth),
+	}
+}
+
+// run checks the links of handler every interval, give or take the jitter,
+// until ctx is done
+func (c *healthChecker) run(ctx context.Context, handler *Handler) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		c.checkAll(ctx, handler.getConfig())
+		timer.Reset(jitter(c.interval, c.jitter))
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// checkAll checks every link of config concurrently and records the
+// results. Links sharing a URL are checked once, with the expected status
+// codes of the first one.
+func (c *healthChecker) checkAll(ctx context.Context, config Configuration) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	results := make(map[string]linkHealth)
+	seen := make(map[string]bool)
+	forEachLink(config, func(link Link) {
+		if seen[link.Url] {
+			return
+		}
+		seen[link.Url] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			health := c.check(ctx, link.Url, link.ExpectStatus)
+			mu.Lock()
+			results[link.Url] = health
+			mu.Unlock()
+		}()
+	})
+	wg.Wait()
+	if ctx.Err() != nil {
+		// Checks cut short by the shutdown would all look down
+		return
+	}
+
+	c.mu.Lock()
+	c.health = results
+	c.mu.Unlock()
+}
+
+// results returns a copy of the last check of every link
+func (c *healthChecker) results() map[string]linkHealth {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return maps.Clone(c.health)
+}
+
+// restore sets the results of checks made before a restart, they are
+// replaced by the next round of checks
+func (c *healthChecker) restore(health map[string]linkHealth) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for url, result := range health {
+		c.health[url] = result
+	}
+}
+
+// check probes a single URL, see isUp for expect
+func (c *healthChecker) check(ctx context.Context, url string, expect []int) linkHealth {
+	if err := c.limiter.acquire(ctx); err != nil {
+		return linkHealth{Checked: time.Now(), Err: err.Error()}
+	}
+	defer c.limiter.release()
+
+	health := linkHealth{Checked: time.Now()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		health.Err = err.Error()
+		return health
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		health.Err = err.Error()
+		slog.Debug("Link check failed", "url", url, "error", err)
+		return health
+	}
+	resp.Body.Close()
+	health.Code = resp.StatusCode
+	health.Up = isUp(resp.StatusCode, expect)
+	health.FrameBlocked = blocksFraming(resp.Header)
+	return health
+}
+
+// isUp reports whether a check answered with code found the link up: code
+// is one of expect, or any 2xx or 3xx when expect is empty
+func isUp(code int, expect []int) bool {
+	if len(expect) == 0 {
+		return code >= 200 && code < 400
+	}
+	return slices.Contains(expect, code)
+}
+
+// blocksFraming reports whether header keeps the page out of frames on
+// other sites, through X-Frame-Options or a CSP frame-ancestors directive
+// that doesn't allow every origin
+func blocksFraming(header http.Header) bool {
+	switch strings.ToUpper(strings.TrimSpace(header.Get("X-Frame-Options"))) {
+	case "DENY", "SAMEORIGIN":
+		return true
+	}
+	for _, policy := range header.Values("Content-Security-Policy") {
+		for _, directive := range strings.Split(policy, ";") {
+			fields := strings.Fields(directive)
+			if len(fields) > 0 && strings.EqualFold(fields[0], "frame-ancestors") && !slices.Contains(fields[1:], "*") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// status returns how url should be displayed: up, down, stale when the
+// last check is older than the staleness window, or empty when it was
+// never checked
+func (c *healthChecker) status(url string, now time.Time) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.statusLocked(url, now)
+}
+
+// statusLocked is status for callers holding c.mu
+func (c *healthChecker) statusLocked(url string, now time.Time) string {
+	health, ok := c.health[url]
+
+	switch {
+	case !ok || health.Checked.IsZero():
+		return ""
+	case now.Sub(health.Checked) > c.stale:
+		return statusStale
+	case health.Up:
+		return statusUp
+	default:
+		return statusDown
+	}
+}
+
+// annotate returns a copy of config with the status of every link filled in
+func (c *healthChecker) annotate(config Configuration) Configuration {
+	now := time.Now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return mapLinks(config, func(link Link) Link {
+		link.Status = c.statusLocked(link.Url, now)
+		link.PreviewBlocked = link.Preview && c.health[link.Url].FrameBlocked
+		return link
+	})
+}
+
+// healthCheck is one sub-check of /healthz?deep=1
+type healthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// deepHealth checks that the configuration can still be read, unless it
+// came from stdin, and that it has links
+func (h *Handler) deepHealth(ctx context.Context) []healthCheck {
+	if h.configFile == stdinConfig {
+		// stdin was consumed by the initial load, there is nothing to read
+		return []healthCheck{h.linksCheck()}
+	}
+	readable := healthCheck{Name: "config_readable", OK: true}
+	sources, err := expandConfigSource(h.configFile)
+	for _, source := range sources {
+		if err != nil {
+			break
+		}
+		_, err = readConfigSource(ctx, source, h.load.client)
+	}
+	if err != nil {
+		readable.OK = false
+		readable.Error = err.Error()
+	}
+
+	return []healthCheck{readable, h.linksCheck()}
+}
+
+// linksCheck checks that the configuration has links
+func (h *Handler) linksCheck() healthCheck {
+	links := healthCheck{Name: "links_configured", OK: h.getConfig().linkCount() > 0}
+	if !links.OK {
+		links.Error = "the configuration has no links"
+	}
+	return links
+}
+
+// healthz reports that the server is alive. With ?deep=1 it also runs
+// deepHealth and answers with the JSON result of each check. With
+// ?links=1 it reports on the checked links instead: 200 when at least the
+// threshold fraction of them is up, 503 listing the others otherwise.
+func (h *Handler) healthz(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Query().Get("deep") != "" {
+		checks := h.deepHealth(req.Context())
+		status := "ok"
+		for _, check := range checks {
+			if !check.OK {
+				status = "fail"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": status, "checks": checks})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if req.URL.Query().Get("links") == "" {
+		io.WriteString(w, "ok\n")
+		return
+	}
+	if h.health == nil {
+		http.Error(w, "link checks are disabled, see -check-interval", http.StatusNotImplemented)
+		return
+	}
+
+	var checked, up int
+	var down []string
+	now := time.Now()
+	forEachLink(h.getConfig(), func(link Link) {
+		switch h.health.status(link.Url, now) {
+		case "":
+			// Not checked yet, nothing to report on
+		case statusUp:
+			checked++
+			up++
+		default:
+			checked++
+			down = append(down, fmt.Sprintf("%s (%s)", link.Name, link.Url))
+		}
+	})
+
+	if checked > 0 && float64(up) < h.health.threshold*float64(checked) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprintf(w, "%d/%d links up\n", up, checked)
+	for _, name := range down {
+		fmt.Fprintf(w, "down: %s\n", name)
+	}
+}
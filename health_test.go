@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStatusServer answers every request with code
+func newStatusServer(t *testing.T, code int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// checkedHandler returns a handler serving config whose links were all
+// checked once
+func checkedHandler(t *testing.T, config Configuration) *Handler {
+	t.Helper()
+	h := newTestHandler(t, config)
+	h.health = newHealthChecker(time.Hour, 0, 1, 0, http.DefaultClient, nil)
+	h.health.checkAll(context.Background(), config)
+	return h
+}
+
+func TestHealthzLinks(t *testing.T) {
+	up := newStatusServer(t, http.StatusOK).URL
+	down := newStatusServer(t, http.StatusInternalServerError).URL
+
+	tests := []struct {
+		name     string
+		links    []Link
+		want     int
+		wantBody []string
+	}{
+		{
+			name:     "all up",
+			links:    []Link{{Name: "Grafana", Url: up + "/grafana"}, {Name: "Wiki", Url: up + "/wiki"}},
+			want:     http.StatusOK,
+			wantBody: []string{"2/2 links up\n"},
+		},
+		{
+			name:     "some down",
+			links:    []Link{{Name: "Grafana", Url: up}, {Name: "Broken", Url: down}},
+			want:     http.StatusServiceUnavailable,
+			wantBody: []string{"1/2 links up\n", "down: Broken (" + down + ")\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := checkedHandler(t, Configuration{Links: tt.links})
+			rec := get(t, http.HandlerFunc(h.healthz), "/healthz?links=1")
+			if rec.Code != tt.want {
+				t.Errorf("GET /healthz?links=1 = %d, want %d", rec.Code, tt.want)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body %q does not contain %q", rec.Body, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthzLinksThreshold(t *testing.T) {
+	up := newStatusServer(t, http.StatusOK).URL
+	down := newStatusServer(t, http.StatusBadGateway).URL
+	config := Configuration{Links: []Link{{Name: "A", Url: up + "/a"}, {Name: "B", Url: up + "/b"}, {Name: "C", Url: down}}}
+	h := checkedHandler(t, config)
+	h.health.threshold = 0.5
+	if rec := get(t, http.HandlerFunc(h.healthz), "/healthz?links=1"); rec.Code != http.StatusOK {
+		t.Errorf("2 of 3 links up with a 0.5 threshold: %d, want 200", rec.Code)
+	}
+}
+
+func TestHealthzLinksDisabled(t *testing.T) {
+	h := newTestHandler(t, Configuration{})
+	if rec := get(t, http.HandlerFunc(h.healthz), "/healthz?links=1"); rec.Code != http.StatusNotImplemented {
+		t.Errorf("GET /healthz?links=1 without checks = %d, want 501", rec.Code)
+	}
+}
+
+func TestHealthzDeep(t *testing.T) {
+	links := Configuration{Links: []Link{{Name: "A", Url: "http://a.local"}}}
+	tests := []struct {
+		name       string
+		configFile string
+		config     Configuration
+		want       int
+		wantChecks map[string]bool
+	}{
+		{
+			name:       "healthy",
+			configFile: "testdata/links.yaml",
+			config:     links,
+			want:       http.StatusOK,
+			wantChecks: map[string]bool{"config_readable": true, "links_configured": true},
+		},
+		{
+			name:       "unreadable config",
+			configFile: "testdata/missing.yaml",
+			config:     links,
+			want:       http.StatusServiceUnavailable,
+			wantChecks: map[string]bool{"config_readable": false, "links_configured": true},
+		},
+		{
+			name:       "no links",
+			configFile: "testdata/links.yaml",
+			want:       http.StatusServiceUnavailable,
+			wantChecks: map[string]bool{"config_readable": true, "links_configured": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, tt.config)
+			h.configFile = tt.configFile
+
+			// The default stays a shallow liveness check
+			if rec := get(t, http.HandlerFunc(h.healthz), "/healthz"); rec.Code != http.StatusOK || rec.Body.String() != "ok\n" {
+				t.Errorf("GET /healthz = %d %q, want 200 ok", rec.Code, rec.Body)
+			}
+
+			rec := get(t, http.HandlerFunc(h.healthz), "/healthz?deep=1")
+			if rec.Code != tt.want {
+				t.Errorf("GET /healthz?deep=1 = %d, want %d", rec.Code, tt.want)
+			}
+			var body struct {
+				Status string        `json:"status"`
+				Checks []healthCheck `json:"checks"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if wantStatus := map[bool]string{true: "ok", false: "fail"}[tt.want == http.StatusOK]; body.Status != wantStatus {
+				t.Errorf("status = %q, want %q", body.Status, wantStatus)
+			}
+			got := map[string]bool{}
+			for _, check := range body.Checks {
+				got[check.Name] = check.OK
+				if !check.OK && check.Error == "" {
+					t.Errorf("failed check %s has no error", check.Name)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantChecks) {
+				t.Errorf("checks = %v, want %v", got, tt.wantChecks)
+			}
+		})
+	}
+}
+
+func TestIsUp(t *testing.T) {
+	tests := []struct {
+		code   int
+		expect []int
+		want   bool
+	}{
+		{http.StatusOK, nil, true},
+		{http.StatusFound, nil, true},
+		{http.StatusUnauthorized, nil, false},
+		{http.StatusInternalServerError, nil, false},
+		{http.StatusUnauthorized, []int{http.StatusUnauthorized}, true},
+		{http.StatusOK, []int{http.StatusUnauthorized}, false},
+		{http.StatusFound, []int{http.StatusOK, http.StatusFound}, true},
+	}
+	for _, tt := range tests {
+		if got := isUp(tt.code, tt.expect); got != tt.want {
+			t.Errorf("isUp(%d, %v) = %v, want %v", tt.code, tt.expect, got, tt.want)
+		}
+	}
+}
+
+func TestExpectStatusCheck(t *testing.T) {
+	unauthorized := newStatusServer(t, http.StatusUnauthorized).URL
+	config := Configuration{Links: []Link{
+		{Name: "Expects 401", Url: unauthorized + "/expected", ExpectStatus: []int{http.StatusUnauthorized}},
+		{Name: "Default", Url: unauthorized + "/default"},
+	}}
+	h := checkedHandler(t, config)
+	now := time.Now()
+	if status := h.health.status(unauthorized+"/expected", now); status != statusUp {
+		t.Errorf("a 401 expected by the link is %q, want up", status)
+	}
+	if status := h.health.status(unauthorized+"/default", now); status != statusDown {
+		t.Errorf("a 401 with the default expectation is %q, want down", status)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Icon fallback steps, listed in icon_fallback
+const (
+	// iconExplicit is the icon set on the link
+	iconExplicit = "icon"
+	// iconFavicon is the icon fetched with -favicons
+	iconFavicon = "favicon"
+	// iconMonogram is the first letter of the link name on a color derived
+	// from the name
+	iconMonogram = "monogram"
+	// iconGlyph is the generic defaultFavicon
+	iconGlyph = "glyph"
+)
+
+var iconStepNames = []string{iconExplicit, iconFavicon, iconMonogram, iconGlyph}
+
+// defaultIconFallback is used when the configuration sets no chain. The
+// glyph then only replaces an icon failing to load, links without an
+// icon or a favicon have none.
+var defaultIconFallback = []string{iconExplicit, iconFavicon, iconGlyph}
+
+// checkIconFallback rejects unknown icon_fallback entries
+func checkIconFallback(config Configuration) error {
+	for _, step := range config.IconFallback {
+		if !slices.Contains(iconStepNames, step) {
+			return fmt.Errorf("invalid icon fallback %q: must be one of %s", step, strings.Join(iconStepNames, ", "))
+		}
+	}
+	return nil
+}
+
+// iconChain returns the icons to try for link, in order: the page shows
+// the first one and moves to the next when it fails to load. Steps that
+// don't apply to the link, such as favicon without -favicons, are skipped.
+func iconChain(steps []string, link Link) []string {
+	explicit := len(steps) > 0
+	if !explicit {
+		if link.Icon == "" && link.Favicon == "" {
+			return nil
+		}
+		steps = defaultIconFallback
+	}
+	var icons []string
+	for _, step := range steps {
+		switch {
+		case step == iconExplicit && link.Icon != "":
+			icons = append(icons, link.Icon)
+		case step == iconFavicon && link.Favicon != "":
+			icons = append(icons, link.Favicon+"?"+faviconFallbackParam)
+		case step == iconMonogram:
+			icons = append(icons, monogramURI(link.Name))
+		case step == iconGlyph:
+			icons = append(icons, defaultFaviconURI)
+		}
+	}
+	return icons
+}
+
+// monogram returns an SVG of the first letter of name, in white on a
+// color picked from a hash of name so it stays the same across renders
+func monogram(name string) string {
+	letter, _ := utf8.DecodeRuneInString(strings.TrimSpace(name))
+	if letter == utf8.RuneError {
+		letter = '?'
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	hue := h.Sum32() % 360
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><rect width="16" height="16" rx="3" fill="hsl(%d,55%%,45%%)"/><text x="8" y="12" font-family="sans-serif" font-size="11" text-anchor="middle" fill="#fff">%s</text></svg>`,
+		hue, html.EscapeString(string(unicode.ToUpper(letter))))
+}
+
+// monogramURI is monogram as a data URI
+func monogramURI(name string) string {
+	return svgDataURI(monogram(name))
+}
+
+// svgDataURI encodes an SVG document as a data URI. Spaces are encoded too
+// so a list of URIs can be joined with spaces.
+func svgDataURI(svg string) string {
+	return "data:image/svg+xml," + strings.NewReplacer(`"`, "%22", "#", "%23", "<", "%3C", ">", "%3E", "%", "%25", " ", "%20").Replace(svg)
+}
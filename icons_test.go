@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIconChain(t *testing.T) {
+	all := []string{iconExplicit, iconFavicon, iconMonogram, iconGlyph}
+	full := Link{Name: "Grafana", Icon: "/icons/grafana.png", Favicon: "/favicons/grafana.local"}
+	tests := []struct {
+		name  string
+		steps []string
+		link  Link
+		want  []string
+	}{
+		{"every stage", all, full, []string{"/icons/grafana.png", "/favicons/grafana.local?fallback", monogramURI("Grafana"), defaultFaviconURI}},
+		{"no explicit icon", all, Link{Name: "Grafana", Favicon: "/favicons/grafana.local"}, []string{"/favicons/grafana.local?fallback", monogramURI("Grafana"), defaultFaviconURI}},
+		{"no favicon", all, Link{Name: "Grafana"}, []string{monogramURI("Grafana"), defaultFaviconURI}},
+		{"glyph only", []string{iconGlyph}, full, []string{defaultFaviconURI}},
+		{"reordered", []string{iconMonogram, iconExplicit}, full, []string{monogramURI("Grafana"), "/icons/grafana.png"}},
+		{"default chain", nil, full, []string{"/icons/grafana.png", "/favicons/grafana.local?fallback", defaultFaviconURI}},
+		{"default chain without icons", nil, Link{Name: "Grafana"}, nil},
+	}
+	for _, tt := range tests {
+		if got := iconChain(tt.steps, tt.link); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: iconChain = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMonogram(t *testing.T) {
+	svg := monogram("grafana")
+	if !strings.Contains(svg, ">G</text>") {
+		t.Errorf("monogram(grafana) = %s, want an uppercase G", svg)
+	}
+	if monogram("grafana") != svg {
+		t.Error("the monogram of a name changes between calls")
+	}
+	if monogram("grafana") == monogram("Grafana") {
+		t.Error("names differing in case get the same monogram")
+	}
+	if svg := monogram("<script>"); !strings.Contains(svg, ">&lt;</text>") {
+		t.Errorf("monogram(<script>) = %s, want the letter escaped", svg)
+	}
+	if svg := monogram("  "); !strings.Contains(svg, ">?</text>") {
+		t.Errorf("monogram of a blank name = %s, want ?", svg)
+	}
+	if uri := monogramURI("Grafana"); !strings.HasPrefix(uri, "data:image/svg+xml,") || strings.ContainsAny(uri, ` <>"#`) {
+		t.Errorf("monogramURI = %s, want an escaped data URI", uri)
+	}
+}
+
+func TestIconFallbackConfig(t *testing.T) {
+	if _, err := loadString(t, "icon_fallback: [icon, sparkles]\n", loadOptions{}); err == nil || !strings.Contains(err.Error(), `invalid icon fallback "sparkles"`) {
+		t.Errorf("load error = %v, want the unknown step rejected", err)
+	}
+	result, err := loadString(t, "icon_fallback: [monogram, glyph]\nlinks:\n  - {name: Router, url: http://router.local}\n", loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := renderIndex(t, result.Config)
+	if !strings.Contains(body, `<img class="icon" src="data:image/svg&#43;xml,%3Csvg`) || !strings.Contains(body, `onerror="nextIcon(this)"`) {
+		t.Errorf("the monogram is not rendered with its fallback:\n%s", body)
+	}
+}
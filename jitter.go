@@ -0,0 +1,16 @@
+package main
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// jitter returns d moved by a random amount of up to percent of d in either
+// direction, so that instances started together don't keep polling in step
+func jitter(d time.Duration, percent float64) time.Duration {
+	if percent <= 0 || d <= 0 {
+		return d
+	}
+	spread := float64(d) * percent / 100
+	return d + time.Duration((rand.Float64()*2-1)*spread)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitterRange(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		percent  float64
+		min, max time.Duration
+	}{
+		{time.Minute, 10, 54 * time.Second, 66 * time.Second},
+		{30 * time.Second, 50, 15 * time.Second, 45 * time.Second},
+		{time.Minute, 100, 0, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		var spread bool
+		for range 1000 {
+			got := jitter(tt.d, tt.percent)
+			if got < tt.min || got > tt.max {
+				t.Fatalf("jitter(%s, %g) = %s, want within [%s, %s]", tt.d, tt.percent, got, tt.min, tt.max)
+			}
+			spread = spread || got != tt.d
+		}
+		if !spread {
+			t.Errorf("jitter(%s, %g) never moved the interval", tt.d, tt.percent)
+		}
+	}
+}
+
+func TestJitterDisabled(t *testing.T) {
+	for _, percent := range []float64{0, -5} {
+		if got := jitter(time.Minute, percent); got != time.Minute {
+			t.Errorf("jitter(1m, %g) = %s, want 1m", percent, got)
+		}
+	}
+	if got := jitter(0, 10); got != 0 {
+		t.Errorf("jitter(0, 10) = %s, want 0", got)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// checkKeys clears the link keyboard shortcuts that can't work as
+// configured and returns a message for each: keys longer than one
+// character, and keys claimed by several links of a page, which only the
+// first link shown keeps
+func checkKeys(config *Configuration) []string {
+	var warnings []string
+	checkPage := func(links []Link, groups []Group) {
+		owners := make(map[string]string)
+		check := func(links []Link) {
+			for i := range links {
+				link := &links[i]
+				if link.Key == "" {
+					continue
+				}
+				if utf8.RuneCountInString(link.Key) != 1 {
+					warnings = append(warnings, fmt.Sprintf("key %q of %q is not a single character", link.Key, link.Name))
+					link.Key = ""
+					continue
+				}
+				if owner, ok := owners[link.Key]; ok {
+					warnings = append(warnings, fmt.Sprintf("key %q is used by both %q and %q, only %q gets it", link.Key, owner, link.Name, owner))
+					link.Key = ""
+					continue
+				}
+				owners[link.Key] = link.Name
+			}
+		}
+		check(links)
+		// orderGroups copies the groups but not their links, so the keys
+		// are cleared in place
+		for _, group := range orderGroups(groups, config.GroupOrder) {
+			check(group.Links)
+		}
+	}
+	checkPage(config.Links, config.Groups)
+	for _, name := range pageNames(*config) {
+		checkPage(config.Pages[name].Links, config.Pages[name].Groups)
+	}
+	return warnings
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckKeys(t *testing.T) {
+	config := Configuration{
+		Links: []Link{
+			{Name: "Grafana", Url: "http://grafana.local", Key: "g"},
+			{Name: "Long", Url: "http://long.local", Key: "gg"},
+			{Name: "Emoji", Url: "http://emoji.local", Key: "é"},
+		},
+		Groups: []Group{
+			{Name: "Work", Links: []Link{{Name: "GitLab", Url: "http://gitlab.local", Key: "g"}}},
+			{Name: "Admin", Links: []Link{{Name: "Wiki", Url: "http://wiki.local", Key: "w"}, {Name: "Webmail", Url: "http://mail.local", Key: "w"}}},
+		},
+		// Admin is shown first, its links keep their keys over Work's
+		GroupOrder: []string{"Admin"},
+		Pages: map[string]Page{
+			// Pages have their own keys
+			"work": {Links: []Link{{Name: "Jira", Url: "http://jira.local", Key: "g"}}},
+		},
+	}
+
+	warnings := checkKeys(&config)
+	if len(warnings) != 3 {
+		t.Errorf("warnings = %q, want the long key and two conflicts", warnings)
+	}
+	for _, want := range []string{`key "gg" of "Long"`, `"Grafana" and "GitLab"`, `"Wiki" and "Webmail"`} {
+		if !strings.Contains(strings.Join(warnings, "\n"), want) {
+			t.Errorf("warnings = %q, want one about %s", warnings, want)
+		}
+	}
+
+	keys := func(links []Link) []string {
+		var keys []string
+		for _, link := range links {
+			keys = append(keys, link.Key)
+		}
+		return keys
+	}
+	for _, tt := range []struct {
+		name  string
+		links []Link
+		want  []string
+	}{
+		{"top-level", config.Links, []string{"g", "", "é"}},
+		{"Work", config.Groups[0].Links, []string{""}},
+		{"Admin", config.Groups[1].Links, []string{"w", ""}},
+		{"work page", config.Pages["work"].Links, []string{"g"}},
+	} {
+		if got := keys(tt.links); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s keys = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnusableKeysAreNotRendered(t *testing.T) {
+	result, err := loadString(t, `
+links:
+  - {name: Grafana, url: "http://grafana.local", key: g}
+  - {name: GitLab, url: "http://gitlab.local", key: g}
+`, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := renderIndex(t, result.Config)
+	if n := strings.Count(body, `data-key="g"`); n != 1 {
+		t.Errorf("%d links have the g shortcut, want 1", n)
+	}
+}
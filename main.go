@@ -1,91 +1,853 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
 	"embed"
+	"encoding/hex"
 	"flag"
 	"fmt"
-	"log"
+	"html/template"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
-	"text/template"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/fsnotify/fsnotify"
-	"gopkg.in/yaml.v3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/netutil"
 )
 
 type Configuration struct {
-	Links []Link `yaml:"links"`
+	// Title is the heading of the main page, "Links" when empty
+	Title  string  `yaml:"title,omitempty"`
+	Links  []Link  `yaml:"links,omitempty"`
+	Groups []Group `yaml:"groups,omitempty"`
+	// Defaults fills in the fields links leave empty, on every page
+	Defaults Link `yaml:"defaults,omitempty"`
+	// Pages are extra pages of links, each served at /<name>
+	Pages map[string]Page `yaml:"pages,omitempty"`
+	// Sources are JSON APIs more links are imported from
+	Sources []Source `yaml:"sources,omitempty"`
+	// HeaderRules show other links at / to requests with a given header,
+	// the first matching rule applies
+	HeaderRules []HeaderRule `yaml:"header_rules,omitempty"`
+	// AutoGroups sorts the top-level links into groups by URL when the
+	// configuration is loaded
+	AutoGroups []AutoGroupRule `yaml:"auto_groups,omitempty"`
+	// GroupOrder lists group names in display order, unlisted groups
+	// come after in alphabetical order
+	GroupOrder []string `yaml:"group_order,omitempty"`
+	// Columns lays the groups out in a grid with that many columns
+	Columns int `yaml:"columns,omitempty"`
+	// Refresh reloads the page every that many seconds, for displays
+	// without JavaScript
+	Refresh int `yaml:"refresh,omitempty"`
+	// HomeURL makes the page heading a link to it
+	HomeURL string `yaml:"home_url,omitempty"`
+	// IconSize is the width and height of link icons in pixels
+	IconSize int `yaml:"icon_size,omitempty"`
+	// AppIcons are the icons of the app installed with -pwa, a default
+	// one is used when empty
+	AppIcons []AppIcon `yaml:"app_icons,omitempty"`
+	// IconFallback lists the icons tried in turn for each link among icon,
+	// favicon, monogram and glyph. When empty, links with an icon or a
+	// favicon show it, then the glyph if it fails to load.
+	IconFallback []string `yaml:"icon_fallback,omitempty"`
+	// SearchFields are the link fields the search box matches, name and
+	// description when empty
+	SearchFields []string `yaml:"search_fields,omitempty"`
+
+	// Hash is the SHA-256 of the raw config file, set by loadConfigContext
+	Hash string `yaml:"-"`
+	// Aliases maps short names to link URLs, built by loadConfigContext
+	Aliases map[string]string `yaml:"-"`
+}
+
+// linkCount returns the number of links, grouped or not, on every page
+func (c Configuration) linkCount() int {
+	n := 0
+	forEachLink(c, func(Link) { n++ })
+	return n
+}
+
+// mapLinks returns a copy of config where every link has been replaced by
+// fn(link). The link slices are cloned so the original is left untouched.
+func mapLinks(config Configuration, fn func(Link) Link) Configuration {
+	mapped := func(links []Link) []Link {
+		links = slices.Clone(links)
+		for i := range links {
+			links[i] = fn(links[i])
+		}
+		return links
+	}
+
+	mappedGroups := func(groups []Group) []Group {
+		groups = slices.Clone(groups)
+		for i := range groups {
+			groups[i].Links = mapped(groups[i].Links)
+		}
+		return groups
+	}
+
+	config.Links = mapped(config.Links)
+	config.Groups = mappedGroups(config.Groups)
+	if config.Pages != nil {
+		pages := make(map[string]Page, len(config.Pages))
+		for name, page := range config.Pages {
+			page.Links = mapped(page.Links)
+			page.Groups = mappedGroups(page.Groups)
+			pages[name] = page
+		}
+		config.Pages = pages
+	}
+	return config
+}
+
+// forEachLink calls fn for every link, top-level ones first, then the
+// links of each group, then those of each page in name order
+func forEachLink(config Configuration, fn func(Link)) {
+	each := func(links []Link, groups []Group) {
+		for _, link := range links {
+			fn(link)
+		}
+		for _, group := range groups {
+			for _, link := range group.Links {
+				fn(link)
+			}
+		}
+	}
+	each(config.Links, config.Groups)
+	for _, name := range pageNames(config) {
+		each(config.Pages[name].Links, config.Pages[name].Groups)
+	}
 }
 
 type Link struct {
-	Name string `yaml:"name"`
-	Url  string `yaml:"url"`
+	Name        string `yaml:"name" json:"name"`
+	Url         string `yaml:"url" json:"url"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Auth marks links that sit behind a VPN or SSO; it only affects
+	// how the link is displayed
+	Auth bool `yaml:"auth,omitempty" json:"auth,omitempty"`
+	// Copyable adds a button copying the URL to the clipboard
+	Copyable bool `yaml:"copyable,omitempty" json:"copyable,omitempty"`
+	// Alias makes the link reachable as /<alias>
+	Alias string `yaml:"alias,omitempty" json:"alias,omitempty"`
+	// Aliases are more short names the link is reachable at, like Alias
+	Aliases []string `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+	// NewTab opens the link in a new tab
+	NewTab bool `yaml:"new_tab,omitempty" json:"new_tab,omitempty"`
+	// Rel is the anchor's rel attribute
+	Rel string `yaml:"rel,omitempty" json:"rel,omitempty"`
+	// Preview adds a button opening the link in a frame on the page
+	Preview bool `yaml:"preview,omitempty" json:"preview,omitempty"`
+	// Key is a single character opening the link when pressed on the page
+	Key string `yaml:"key,omitempty" json:"key,omitempty"`
+	// NoAutoDescription opts the link out of -descriptions
+	NoAutoDescription bool `yaml:"no_auto_description,omitempty" json:"no_auto_description,omitempty"`
+	// Class is added to the anchor's class attribute for custom styling,
+	// it is limited to letters, digits, '-', '_' and spaces
+	Class string `yaml:"class,omitempty" json:"class,omitempty"`
+	// Tags are free-form labels the search box can match
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	// Icon is the URL of an image shown before the link, it comes first in
+	// the icon fallback chain
+	Icon string `yaml:"icon,omitempty" json:"icon,omitempty"`
+	// ExpectStatus lists the status codes that mean the link is up when it
+	// is checked, any 2xx or 3xx when empty
+	ExpectStatus []int `yaml:"expect_status,omitempty" json:"expect_status,omitempty"`
+
+	// Status is the health of the link when checking is enabled, filled
+	// in at render time
+	Status string `yaml:"-" json:"-"`
+	// Icons are the icons to try in turn, see iconChain, filled in at
+	// render time
+	Icons []string `yaml:"-" json:"-"`
+	// Favicon is the path of the fetched icon when favicons are enabled,
+	// filled in at render time
+	Favicon string `yaml:"-" json:"-"`
+	// PreviewBlocked is set at render time when the last health check saw
+	// the site refuse to be framed
+	PreviewBlocked bool `yaml:"-" json:"-"`
+	// Search is the text the search box matches, filled in at render time
+	Search string `yaml:"-" json:"-"`
+}
+
+// aliasNames returns every alias of the link, Alias first
+func (l Link) aliasNames() []string {
+	if l.Alias == "" {
+		return l.Aliases
+	}
+	return append([]string{l.Alias}, l.Aliases...)
+}
+
+// defaultNewTabRel keeps pages opened in a new tab from reaching back to
+// this one through window.opener, and from seeing it as the referrer
+const defaultNewTabRel = "noopener noreferrer"
+
+// RelAttr returns the rel attribute of the link's anchor, defaulting to
+// defaultNewTabRel for links opened in a new tab
+func (l Link) RelAttr() string {
+	if l.Rel == "" && l.NewTab {
+		return defaultNewTabRel
+	}
+	return l.Rel
+}
+
+type Group struct {
+	Name  string `yaml:"name,omitempty"`
+	Links []Link `yaml:"links,omitempty"`
+	// Span is how many grid columns the group takes when Columns is set
+	Span int `yaml:"span,omitempty"`
+	// OpenAll adds a button opening every link of the group in a new tab
+	OpenAll bool `yaml:"open_all,omitempty"`
+}
+
+// AutoGroupRule puts links whose host matches Pattern (e.g. "*.grafana.*")
+// into the group named Group
+type AutoGroupRule struct {
+	Pattern string `yaml:"pattern"`
+	Group   string `yaml:"group"`
 }
 
 type Handler struct {
-	mu       sync.RWMutex
-	config   Configuration
+	mu     sync.RWMutex
+	config Configuration
+	// previous is the configuration replaced by the last reload that
+	// changed the file
+	previous Configuration
+	// warnings were raised loading config
+	warnings []Warning
+	// files are the raw files config was assembled from, for reloading
+	// one of them alone
+	files    []configFile
 	template *template.Template
+	// templatesDir holds templates overriding the embedded ones, they are
+	// reparsed with every reload
+	templatesDir string
+	// load is how every configuration is loaded
+	load    loadOptions
+	metrics *metrics
+	// configFile is where the configuration is loaded from, shown on the
+	// page when it has no links
+	configFile string
+	// health is nil unless link checking is enabled
+	health *healthChecker
+	// favicons is nil unless favicon fetching is enabled
+	favicons *faviconCache
+	// descriptions is nil unless meta description fetching is enabled
+	descriptions *descriptionCache
+	// editor is nil unless editing through the admin page is enabled
+	editor *configEditor
+	// imports adds the links of the configuration sources, it is nil in
+	// handlers not started by main
+	imports *sourceImporter
+	// featured shows a link of the day above the others
+	featured bool
+	// pwa makes the page installable as an app
+	pwa bool
+	// profileRender logs how long every page took to render
+	profileRender bool
+	// tracer is nil unless -otel-endpoint is set
+	tracer *tracer
+	// reloadHook is the command run after every successful reload, see
+	// runReloadHook
+	reloadHook string
+	// rendered is nil unless -render-cache-ttl is set
+	rendered *renderCache
+	// shuffle is nil unless -shuffle is set, it reorders the links of
+	// every configuration the handler is given
+	shuffle *rand.Rand
+}
+
+// pageData is what links.html is rendered with
+type pageData struct {
+	Configuration
+	ConfigFile string
+	// Empty is set when the configuration has no links at all
+	Empty bool
+	// Nav is the navigation bar between pages, empty without pages
+	Nav []navItem
+	// Featured is the link of the day, nil unless -featured is set
+	Featured *Link
+	// PWA links the web manifest and registers the service worker
+	PWA bool
+}
+
+// templateFuncs are the helper functions available to the templates
+var templateFuncs = template.FuncMap{
+	"truncate":    truncate,
+	"span":        span,
+	"defaultIcon": func() template.URL { return template.URL(defaultFaviconURI) },
+	"join":        strings.Join,
+	// sanitizeLinks drops the links whose URL could run script, the
+	// others can use any scheme, not only the http, https and mailto
+	// html/template lets through
+	"linkURL": func(url string) template.URL { return template.URL(url) },
+	// Images can't run script, and the monogram and glyph icons are data
+	// URIs html/template would filter out
+	"iconURL": func(url string) template.URL { return template.URL(url) },
 }
 
-func NewHandler(config Configuration) (*Handler, error) {
-	tmpl, err := template.ParseFS(templatesFS, "templates/*.html")
+// truncate shortens s to at most n characters, appending an ellipsis
+// when it had to cut. It counts runes, not bytes, so multibyte text
+// is never split in the middle of a character.
+func truncate(n int, s string) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// span clamps the column span of a group between 1 and the number of
+// columns of the grid
+func span(n, columns int) int {
+	return max(1, min(n, columns))
+}
+
+// parseTemplates parses the embedded templates and, when dir is set, the
+// *.html files of dir on top of them, so a file there overrides the
+// embedded template of the same name
+func parseTemplates(dir string) (*template.Template, error) {
+	tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templatesFS, "templates/*.html")
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse templates: %w", err)
 	}
+	if dir == "" {
+		return tmpl, nil
+	}
+	if _, err := tmpl.ParseGlob(filepath.Join(dir, "*.html")); err != nil {
+		return nil, fmt.Errorf("failed to parse templates in %s: %w", dir, err)
+	}
+	return tmpl, nil
+}
+
+// NewHandler returns a handler serving config, with the templates of
+// templatesDir overriding the embedded ones when it is set. A broken
+// template there is logged and the embedded ones are used instead, it only
+// fails when those don't parse either.
+func NewHandler(config Configuration, templatesDir string) (*Handler, error) {
+	tmpl, err := parseTemplates(templatesDir)
+	if err != nil && templatesDir != "" {
+		slog.Error("Falling back to the embedded templates", "error", err)
+		tmpl, err = parseTemplates("")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := newMetrics()
+	metrics.lastReload = time.Now()
+	metrics.links = config.linkCount()
 
 	return &Handler{
-		config:   config,
-		template: tmpl,
+		config:       config,
+		previous:     config,
+		template:     tmpl,
+		templatesDir: templatesDir,
+		metrics:      metrics,
 	}, nil
 }
 
-func (h *Handler) getConfig() Configuration {
+// snapshot returns the configuration and the template to render it with.
+// They are read in the same critical section so a page is never rendered
+// with a template and a configuration from different reloads.
+func (h *Handler) snapshot() (Configuration, *template.Template) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.config
+	config := h.config
+	if h.imports != nil {
+		config = h.imports.apply(config, h.load.dedupe)
+	}
+	config.Groups = orderGroups(config.Groups, config.GroupOrder)
+	return config, h.template
 }
 
-func (h *Handler) index(w http.ResponseWriter, req *http.Request) {
+func (h *Handler) getConfig() Configuration {
+	config, _ := h.snapshot()
+	return config
+}
+
+// setIndexHeaders sets the headers of the index page, they are shared by
+// GET and HEAD requests
+func setIndexHeaders(w http.ResponseWriter, config Configuration) {
 	w.Header().Set("Content-Type", "text/html")
-	config := h.getConfig()
+	w.Header().Set("X-Config-Hash", config.Hash)
+	varies := make(map[string]bool)
+	for _, rule := range config.HeaderRules {
+		if header := http.CanonicalHeaderKey(rule.Header); !varies[header] {
+			varies[header] = true
+			w.Header().Add("Vary", header)
+		}
+	}
+}
+
+func (h *Handler) index(w http.ResponseWriter, req *http.Request) {
+	config, tmpl := h.snapshot()
+	name := strings.TrimPrefix(req.URL.Path, "/")
+	if target, ok := config.Aliases[name]; ok {
+		http.Redirect(w, req, target, http.StatusFound)
+		return
+	}
+	// Rules only replace the main page, the others stay reachable
+	cacheKey := name
+	var rule *HeaderRule
+	if i := matchHeaderRule(config.HeaderRules, req.Header); name == "" && i >= 0 {
+		rule = &config.HeaderRules[i]
+		name = rule.Page
+		cacheKey = fmt.Sprintf("/rule/%d", i)
+	}
+	view, ok := config.view(name)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	if rule != nil {
+		view = rule.filterGroups(view)
+	}
+	if req.URL.Query().Get("group") == "tag" {
+		view = groupByTag(view)
+		cacheKey += "?group=tag"
+	}
+	nav := navigation(config, name)
+	config = view
+
+	setIndexHeaders(w, config)
+	if req.Method == http.MethodHead {
+		// Monitoring only needs the headers, skip rendering the page
+		return
+	}
+	if h.rendered != nil {
+		body, ok := h.rendered.get(cacheKey, config.Hash, tmpl)
+		h.metrics.observeRenderCache(ok)
+		if ok {
+			w.Write(body)
+			return
+		}
+	}
+	if h.health != nil {
+		config = h.health.annotate(config)
+	}
+	if h.descriptions != nil {
+		config = h.descriptions.annotate(config)
+	}
+	if h.favicons != nil {
+		config = mapLinks(config, func(link Link) Link {
+			link.Favicon = h.favicons.path(link.Url)
+			return link
+		})
+	}
+	searched := config
+	config = mapLinks(config, func(link Link) Link {
+		link.Search = searchText(searched, link)
+		link.Icons = iconChain(searched.IconFallback, link)
+		return link
+	})
 	// Execute the template by name
-	if err := h.template.ExecuteTemplate(w, "links.html", config); err != nil {
+	data := pageData{
+		Configuration: config,
+		ConfigFile:    h.configFile,
+		Empty:         config.linkCount() == 0,
+		Nav:           nav,
+		PWA:           h.pwa,
+	}
+	if h.featured {
+		if link, ok := featuredLink(config, time.Now()); ok {
+			// The link is also in its usual place, which keeps the shortcut
+			link.Key = ""
+			data.Featured = &link
+		}
+	}
+	var buf bytes.Buffer
+	renderStart := time.Now()
+	if err := tmpl.ExecuteTemplate(&buf, "links.html", data); err != nil {
 		http.Error(w, fmt.Sprintf("Error rendering template: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if h.profileRender {
+		slog.Info("Page rendered", "view", cacheKey, "links", config.linkCount(), "bytes", buf.Len(), "duration", time.Since(renderStart).String())
+	}
+	if h.rendered != nil {
+		h.rendered.put(cacheKey, config.Hash, tmpl, buf.Bytes())
+	}
+	w.Write(buf.Bytes())
 }
 
-func (h *Handler) updateConfig(config Configuration) {
+func (h *Handler) updateConfig(result LoadResult) (previous Configuration) {
+	return h.update(result, nil)
+}
+
+// update swaps in the configuration and warnings of result and, unless it
+// is nil, tmpl in a single critical section. It returns the configuration
+// it replaced.
+func (h *Handler) update(result LoadResult, tmpl *template.Template) (previous Configuration) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	previous = h.config
+	config := result.Config
+	if h.shuffle != nil {
+		shuffleLinks(&config, h.shuffle)
+	}
+	// Editors and Kubernetes often trigger several reloads for one change,
+	// only a new revision replaces the previous one
+	if config.Hash != h.config.Hash {
+		h.previous = h.config
+	}
 	h.config = config
-	log.Printf("Configuration updated: %+v\n", config)
+	h.warnings = result.Warnings
+	h.files = result.files
+	if tmpl != nil {
+		h.template = tmpl
+	}
+	h.metrics.setLinks(config.linkCount())
+	slog.Debug("Configuration updated", "links", config.linkCount(), "hash", config.Hash)
+	return previous
+}
+
+// reload loads the configuration at configPath and parses the templates,
+// then applies both at once. Nothing is applied if either fails. It returns
+// the configuration replaced, the reload is traced under the span of ctx.
+func (h *Handler) reload(ctx context.Context, configPath string) (result LoadResult, previous Configuration, err error) {
+	start := time.Now()
+	reloadSpan := h.tracer.start(ctx, "config.reload", spanKindInternal)
+	defer func() {
+		h.metrics.observeReload(time.Since(start), err)
+		h.tracer.end(reloadSpan, err)
+	}()
+
+	result, err = loadConfigContext(ctx, configPath, h.load)
+	if err != nil {
+		return LoadResult{}, Configuration{}, err
+	}
+	tmpl, err := parseTemplates(h.templatesDir)
+	if err != nil {
+		return LoadResult{}, Configuration{}, err
+	}
+	previous = h.update(result, tmpl)
+	h.reloaded(result)
+	return result, previous, nil
+}
+
+// reloaded logs a successful reload and starts the reload hook, it is
+// called by every kind of reload
+func (h *Handler) reloaded(result LoadResult) {
+	config := result.Config
+	slog.Info("Configuration reloaded", "links", config.linkCount(), "sha256", config.Hash, "warnings", len(result.Warnings))
+	if h.reloadHook != "" {
+		go runReloadHook(h.reloadHook, config.linkCount())
+	}
 }
 
 // LoadConfig loads configuration from file
-func loadConfig(filename string) (Configuration, error) {
-	f, err := os.ReadFile(filename)
+func loadConfig(filename string, opts loadOptions) (LoadResult, error) {
+	return loadConfigContext(context.Background(), filename, opts)
+}
+
+// loadOptions are the command line settings that change how the
+// configuration is loaded
+type loadOptions struct {
+	// categoriesDir holds one group per file, merged into the
+	// configuration
+	categoriesDir string
+	// format is the format of the configuration files, detected from
+	// their extension when empty
+	format string
+	// client fetches remote configurations, http.DefaultClient when nil
+	client *http.Client
+	// strict fails the load on invalid links rather than skipping them
+	strict bool
+	// dedupe drops the links whose URL appeared earlier on their page
+	dedupe bool
+	// csvColumns maps link fields to the CSV columns they are read from
+	csvColumns map[string]string
+}
+
+// isRemoteConfig reports whether the configuration is fetched over HTTP
+// rather than read from disk
+func isRemoteConfig(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// stdinConfig is the -config value reading the configuration from stdin
+const stdinConfig = "-"
+
+// embeddedConfigSource is the source of the configuration built into the
+// binary with the embed_config tag
+const embeddedConfigSource = "embedded:config.yaml"
+
+// isMissingConfig reports whether source names a local file that doesn't
+// exist, which the embedded configuration then replaces
+func isMissingConfig(source string) bool {
+	if isRemoteConfig(source) || isConfigPattern(source) || source == stdinConfig {
+		return false
+	}
+	_, err := os.Stat(source)
+	return os.IsNotExist(err)
+}
+
+// readConfigSource returns the raw configuration from a local file, stdin,
+// the binary or, for http(s) URLs, from a remote server. Cancelling ctx
+// aborts the fetch.
+func readConfigSource(ctx context.Context, source string, client *http.Client) ([]byte, error) {
+	if source == stdinConfig {
+		return io.ReadAll(os.Stdin)
+	}
+	if source == embeddedConfigSource {
+		return embeddedConfig, nil
+	}
+	if !isRemoteConfig(source) {
+		return os.ReadFile(source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
 	if err != nil {
-		return Configuration{}, err
+		return nil, err
 	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected status %s", source, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
 
+// configFile is the raw content of one of the configuration files
+type configFile struct {
+	source string
+	data   []byte
+}
+
+// loadConfigContext loads configuration from a file, a glob of files or a
+// URL, giving up when ctx is done. Files matched by a glob are merged in
+// lexical order, then the groups of the categories directory when it is
+// set.
+func loadConfigContext(ctx context.Context, filename string, opts loadOptions) (LoadResult, error) {
+	sources, err := expandConfigSource(filename)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	files := make([]configFile, 0, len(sources))
+	for _, source := range sources {
+		data, err := readConfigSource(ctx, source, opts.client)
+		if err != nil {
+			return LoadResult{}, err
+		}
+		files = append(files, configFile{source: source, data: data})
+	}
+	return assembleConfig(files, opts)
+}
+
+// assembleConfig decodes and merges files, in order, then the categories
+// directory, and finishes the result. The files are decoded again on
+// every call so the configurations built from them never share slices.
+func assembleConfig(files []configFile, opts loadOptions) (LoadResult, error) {
 	var config Configuration
-	if err := yaml.Unmarshal(f, &config); err != nil {
-		return Configuration{}, err
+	var skipped []Warning
+	hash := sha256.New()
+	for _, file := range files {
+		c, warnings, err := decodeConfig(file.data, configFormat(file.source, opts.format), opts)
+		if err != nil {
+			return LoadResult{}, fmt.Errorf("%s: %w", file.source, err)
+		}
+		for _, warning := range warnings {
+			warning.Message = fmt.Sprintf("%s: %s", file.source, warning.Message)
+			if opts.strict {
+				return LoadResult{}, fmt.Errorf("invalid link under -strict: %s", warning)
+			}
+			slog.Warn("Ignoring invalid link", "warning", warning.String())
+			skipped = append(skipped, warning)
+		}
+		hash.Write(file.data)
+		config = mergeConfigs(config, c)
+	}
+	if opts.categoriesDir != "" {
+		categories, err := loadCategories(opts.categoriesDir, hash)
+		if err != nil {
+			return LoadResult{}, err
+		}
+		config = mergeConfigs(config, categories)
 	}
-	return config, nil
+
+	warnings, err := finishConfig(&config, opts)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	config.Hash = hex.EncodeToString(hash.Sum(nil))
+	return LoadResult{Config: config, Warnings: append(skipped, warnings...), files: files}, nil
+}
+
+// defaultIconSize is the icon size used when the configuration sets none
+const defaultIconSize = 16
+
+// finishConfig applies the load-time processing to a freshly parsed
+// configuration: defaults are filled in, auto groups resolved, invalid
+// links skipped, duplicates dropped with opts.dedupe, unusable keyboard
+// shortcuts cleared and aliases indexed. The warnings are logged and
+// returned; with opts.strict, an invalid link fails the load instead.
+func finishConfig(config *Configuration, opts loadOptions) ([]Warning, error) {
+	if config.IconSize <= 0 {
+		config.IconSize = defaultIconSize
+	}
+	if err := applyAutoGroups(config); err != nil {
+		return nil, err
+	}
+	if err := finishPages(config); err != nil {
+		return nil, err
+	}
+	if err := checkSearchFields(*config); err != nil {
+		return nil, err
+	}
+	if err := checkIconFallback(*config); err != nil {
+		return nil, err
+	}
+	if err := checkHeaderRules(*config); err != nil {
+		return nil, err
+	}
+	applyDefaults(config)
+	warnings := sanitizeLinks(config)
+	if opts.strict && len(warnings) > 0 {
+		return nil, fmt.Errorf("invalid link under -strict: %s", warnings[0])
+	}
+	for _, warning := range warnings {
+		// The link is skipped, or only its alias when that is the issue
+		slog.Warn("Ignoring invalid link", "warning", warning.String())
+	}
+	if opts.dedupe {
+		var dropped []Warning
+		*config, dropped = dedupeLinks(*config)
+		for _, warning := range dropped {
+			slog.Warn("Dropping duplicate link", "warning", warning.String())
+		}
+		warnings = append(warnings, dropped...)
+	}
+	for _, warning := range checkKeys(config) {
+		slog.Warn("Unusable keyboard shortcut", "warning", warning)
+		warnings = append(warnings, Warning{Message: warning})
+	}
+	var err error
+	if config.Aliases, err = buildAliases(*config); err != nil {
+		return nil, err
+	}
+	return warnings, nil
 }
 
+// scriptSchemes are the URL schemes browsers run or render as a document
+// from a link, which links can't use
+var scriptSchemes = []string{"javascript:", "vbscript:", "data:"}
+
+// isScriptURL reports whether following rawURL could run script. Browsers
+// ignore case, leading spaces and tabs or newlines inside the scheme, so
+// they are dropped before comparing.
+func isScriptURL(rawURL string) bool {
+	scheme := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, rawURL)
+	for _, prefix := range scriptSchemes {
+		if strings.HasPrefix(scheme, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validClass matches the class attributes links may set, one or more
+// class names that can't break out of the attribute
+var validClass = regexp.MustCompile(`^[A-Za-z0-9_ -]*$`)
+
+// reloadHookTimeout bounds how long a reload hook may run
+const reloadHookTimeout = 30 * time.Second
+
 //go:embed templates/*
 var templatesFS embed.FS
 
 type AppConfig struct {
-	ConfigFile string
-	BindAddr   string
-	BindPort   int
+	ConfigFile       string
+	BindAddr         string
+	BindPort         int
+	LogExtended      bool
+	LogSample        float64
+	Whoami           bool
+	ProfileRender    bool
+	OTelEndpoint     string
+	TrustedProxies   []netip.Prefix
+	ShutdownTimeout  time.Duration
+	Probe            bool
+	GzipLevel        int
+	GzipMinLength    int
+	ReloadHook       string
+	MaxConns         int
+	H2C              bool
+	RenderCacheTTL   time.Duration
+	Featured         bool
+	PWA              bool
+	KeepAlive        bool
+	IdleTimeout      time.Duration
+	LogLevel         slog.Level
+	LogFormat        string
+	RobotsFile       string
+	PrintRoutes      bool
+	CheckInterval    time.Duration
+	Jitter           float64
+	CheckStale       time.Duration
+	HealthThreshold  float64
+	Favicons         bool
+	FaviconCacheDir  string
+	FaviconTTL       time.Duration
+	Descriptions     bool
+	FetchConcurrency int
+	FetchTimeout     time.Duration
+	DialTimeout      time.Duration
+	TLSTimeout       time.Duration
+	HeaderTimeout    time.Duration
+	FaviconWorkers   int
+	AuthUser         string
+	AuthPass         string
+	Shuffle          bool
+	TemplatesDir     string
+	CategoriesDir    string
+	ConfigFormat     string
+	Strict           bool
+	Dedupe           bool
+	CSVColumns       map[string]string
+	FileMode         os.FileMode
+	StateDir         string
+	ReadOnly         bool
+	ReloadToken      string
+	TLSCert          string
+	TLSKey           string
+	ClientCA         string
+	Version          bool
+	TLSMinVersion    string
+	TLSCiphers       string
+	ShuffleSeed      uint64
 }
 
 func parseFlags() AppConfig {
@@ -100,6 +862,84 @@ func parseFlags() AppConfig {
 	flag.IntVar(&appConfig.BindPort, "port", 8080, "Port to bind the server")
 	flag.IntVar(&appConfig.BindPort, "p", 8080, "Port to bind the server (shorthand)")
 
+	flag.TextVar(&appConfig.LogLevel, "log-level", slog.LevelInfo, "Minimum log level: debug, info, warn or error")
+	flag.StringVar(&appConfig.LogFormat, "log-format", "text", "Log format: text or json")
+	flag.BoolVar(&appConfig.LogExtended, "log-extended", false, "Include User-Agent and Referer in the access log")
+	flag.Float64Var(&appConfig.LogSample, "log-sample", 1, "Fraction of successful requests written to the access log, errors are always logged")
+
+	flag.DurationVar(&appConfig.ShutdownTimeout, "shutdown-timeout", 10*time.Second, "Time to wait for in-flight requests on shutdown")
+
+	flag.IntVar(&appConfig.GzipLevel, "gzip-level", 6, "Gzip compression level, from 1 (fastest) to 9 (smallest)")
+	flag.IntVar(&appConfig.GzipMinLength, "gzip-min-length", 1024, "Minimum response size in bytes before compressing")
+
+	flag.StringVar(&appConfig.ReloadHook, "reload-hook", "", "Command to run after each successful config reload, with the link count as last argument")
+
+	flag.IntVar(&appConfig.MaxConns, "max-conns", 0, "Maximum number of concurrent connections (0 means unlimited)")
+
+	flag.BoolVar(&appConfig.H2C, "h2c", false, "Accept cleartext HTTP/2 (h2c) in addition to HTTP/1.1")
+	flag.DurationVar(&appConfig.RenderCacheTTL, "render-cache-ttl", 0, "Serve rendered pages from memory for this long, or until the config changes (0 disables the cache)")
+	flag.BoolVar(&appConfig.PWA, "pwa", false, "Serve a web manifest and service worker so the page can be installed as an app")
+	flag.BoolVar(&appConfig.Featured, "featured", false, "Show a link of the day above the others, rotating daily")
+	flag.BoolVar(&appConfig.KeepAlive, "keepalive", true, "Keep client connections open between requests (-keepalive=false closes them after each response)")
+	flag.DurationVar(&appConfig.IdleTimeout, "idle-timeout", 0, "How long an idle kept-alive connection stays open (0 for no limit)")
+
+	flag.StringVar(&appConfig.TLSCert, "tls-cert", "", "Certificate file to serve HTTPS with, together with -tls-key")
+	flag.StringVar(&appConfig.TLSKey, "tls-key", "", "Private key file of -tls-cert")
+	flag.StringVar(&appConfig.TLSMinVersion, "tls-min-version", "1.2", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	flag.StringVar(&appConfig.TLSCiphers, "tls-ciphers", "", "Comma separated TLS 1.2 cipher suites to allow, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 (default Go's)")
+	flag.StringVar(&appConfig.ClientCA, "client-ca", "", "CA file clients must present a certificate from (requires TLS)")
+
+	flag.StringVar(&appConfig.TemplatesDir, "templates", "", "Directory of templates overriding the embedded ones, reloaded with the config")
+	flag.StringVar(&appConfig.CategoriesDir, "categories-dir", "", "Directory of YAML files each defining one group, merged into the config")
+	flag.StringVar(&appConfig.ConfigFormat, "config-format", "", "Format of the config files: yaml, json, text or csv (default detected from the extension)")
+	csvColumns := flag.String("csv-columns", "", "CSV columns the link fields are read from, e.g. name=Title,url=Link (default the column named after each field)")
+	flag.BoolVar(&appConfig.Strict, "strict", false, "Fail loading the config on invalid links instead of skipping them with a warning")
+	flag.BoolVar(&appConfig.Dedupe, "dedupe", false, "Drop the links whose URL already appears earlier on the same page, once the config files are merged")
+	flag.StringVar(&appConfig.RobotsFile, "robots", "", "Path to a custom robots.txt (default disallows all crawlers)")
+
+	flag.DurationVar(&appConfig.CheckInterval, "check-interval", 0, "Interval between link health checks (0 disables checking)")
+	flag.DurationVar(&appConfig.CheckStale, "check-stale", 0, "Age after which a health result is shown as stale (default 3x -check-interval)")
+	flag.Float64Var(&appConfig.Jitter, "jitter", 0, "Percentage by which link check, description rescan and source import intervals are randomly moved either way")
+	flag.Float64Var(&appConfig.HealthThreshold, "health-threshold", 1, "Fraction of checked links that must be up for /healthz?links=1 to succeed")
+	flag.StringVar(&appConfig.StateDir, "state-dir", "", "Directory to save the link check results in, so they survive restarts (disabled when empty)")
+
+	flag.IntVar(&appConfig.FetchConcurrency, "fetch-concurrency", 0, "Maximum outbound requests in flight across link checks, favicons and descriptions (0 for no limit)")
+	flag.DurationVar(&appConfig.FetchTimeout, "fetch-timeout", 10*time.Second, "Timeout of outbound requests, response body included")
+	flag.DurationVar(&appConfig.DialTimeout, "fetch-dial-timeout", 5*time.Second, "Timeout for outbound requests to connect")
+	flag.DurationVar(&appConfig.TLSTimeout, "fetch-tls-timeout", 5*time.Second, "Timeout of the TLS handshake of outbound requests")
+	flag.DurationVar(&appConfig.HeaderTimeout, "fetch-header-timeout", 10*time.Second, "Timeout waiting for the response headers of outbound requests")
+
+	flag.BoolVar(&appConfig.Favicons, "favicons", false, "Fetch and show the favicon of each link")
+	flag.BoolVar(&appConfig.Descriptions, "descriptions", false, "Fetch the meta description of links that have no description")
+	flag.StringVar(&appConfig.FaviconCacheDir, "favicon-cache-dir", "", "Directory to persist fetched favicons and descriptions in across restarts")
+	flag.IntVar(&appConfig.FaviconWorkers, "favicon-concurrency", 8, "Maximum favicons fetched at once while warming the cache at startup")
+	fileMode := flag.String("file-mode", "0644", "Octal permission of the favicon, description and state files, their directory gets the matching execute bits")
+	flag.DurationVar(&appConfig.FaviconTTL, "favicon-ttl", 24*time.Hour, "How long a fetched favicon or description is kept before being refetched")
+
+	flag.StringVar(&appConfig.AuthUser, "auth-user", "admin", "User name for the admin page and edit API")
+	flag.StringVar(&appConfig.AuthPass, "auth-pass", "", "Password for the admin page and edit API (admin is disabled when empty)")
+	authPassFile := flag.String("auth-pass-file", "", "File holding the -auth-pass password, used instead of the flag")
+
+	flag.BoolVar(&appConfig.Shuffle, "shuffle", false, "Randomize the order of links on every load")
+	flag.Uint64Var(&appConfig.ShuffleSeed, "shuffle-seed", 0, "Seed for -shuffle, to reproduce an order (default random)")
+
+	flag.StringVar(&appConfig.ReloadToken, "reload-token", "", "Bearer token for the /-/ operational endpoints (disabled when empty)")
+	reloadTokenFile := flag.String("reload-token-file", "", "File holding the -reload-token token, used instead of the flag")
+	flag.BoolVar(&appConfig.ReadOnly, "read-only", false, "Reject every request that would change the configuration with 403")
+
+	flag.BoolVar(&appConfig.Whoami, "whoami", false, "Serve /whoami, echoing what the server sees of each request, to debug proxies")
+	trustedProxies := flag.String("trusted-proxies", "", "Comma separated IPs and CIDR prefixes of the proxies whose X-Forwarded-For /whoami trusts")
+
+	flag.BoolVar(&appConfig.ProfileRender, "profile-render", false, "Log how long the template took to render for every page request")
+
+	flag.StringVar(&appConfig.OTelEndpoint, "otel-endpoint", "", "OTLP/HTTP traces URL to send request and reload spans to, e.g. http://localhost:4318/v1/traces (disabled when empty)")
+
+	flag.BoolVar(&appConfig.PrintRoutes, "print-routes", false, "Print the registered routes and exit")
+
+	flag.BoolVar(&appConfig.Version, "version", false, "Print the version and exit")
+
+	flag.BoolVar(&appConfig.Probe, "probe", false, "Verify the configuration loads and the port is bindable, then exit")
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS]\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "A simple link manager with auto-reloading configuration.\n\n")
@@ -112,80 +952,457 @@ func parseFlags() AppConfig {
 
 	flag.Parse()
 
+	if appConfig.GzipLevel < gzip.BestSpeed || appConfig.GzipLevel > gzip.BestCompression {
+		fmt.Fprintf(os.Stderr, "invalid -gzip-level %d: must be between %d and %d\n", appConfig.GzipLevel, gzip.BestSpeed, gzip.BestCompression)
+		os.Exit(2)
+	}
+	if appConfig.ConfigFormat != "" && !slices.Contains(configFormats, appConfig.ConfigFormat) {
+		fmt.Fprintf(os.Stderr, "invalid -config-format %q: must be one of %s\n", appConfig.ConfigFormat, strings.Join(configFormats, ", "))
+		os.Exit(2)
+	}
+	if appConfig.LogSample < 0 || appConfig.LogSample > 1 {
+		fmt.Fprintf(os.Stderr, "invalid -log-sample %g: must be between 0 and 1\n", appConfig.LogSample)
+		os.Exit(2)
+	}
+	if appConfig.Jitter < 0 || appConfig.Jitter > 100 {
+		fmt.Fprintf(os.Stderr, "invalid -jitter %g: must be between 0 and 100\n", appConfig.Jitter)
+		os.Exit(2)
+	}
+	if appConfig.ReloadHook != "" && len(strings.Fields(appConfig.ReloadHook)) == 0 {
+		fmt.Fprintf(os.Stderr, "invalid -reload-hook %q: the command is empty\n", appConfig.ReloadHook)
+		os.Exit(2)
+	}
+	if appConfig.LogFormat != "text" && appConfig.LogFormat != "json" {
+		fmt.Fprintf(os.Stderr, "invalid -log-format %q: must be text or json\n", appConfig.LogFormat)
+		os.Exit(2)
+	}
+	var err error
+	if appConfig.TrustedProxies, err = parseTrustedProxies(*trustedProxies); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -trusted-proxies: %v\n", err)
+		os.Exit(2)
+	}
+	mode, err := strconv.ParseUint(*fileMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		fmt.Fprintf(os.Stderr, "invalid -file-mode %q: must be an octal permission such as 0600\n", *fileMode)
+		os.Exit(2)
+	}
+	appConfig.FileMode = os.FileMode(mode)
+	if appConfig.CSVColumns, err = parseCSVColumns(*csvColumns); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -csv-columns: %v\n", err)
+		os.Exit(2)
+	}
+	for _, secret := range []struct {
+		flag, file string
+		value      *string
+	}{
+		{"-auth-pass-file", *authPassFile, &appConfig.AuthPass},
+		{"-reload-token-file", *reloadTokenFile, &appConfig.ReloadToken},
+	} {
+		if secret.file == "" {
+			continue
+		}
+		value, err := readSecretFile(secret.file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid %s: %v\n", secret.flag, err)
+			os.Exit(2)
+		}
+		*secret.value = value
+	}
+
 	return appConfig
 }
 
-func watchConfig(configPath string, handler *Handler) {
-	watcher, err := fsnotify.NewWatcher()
+// readSecretFile reads a password or token from path, without the trailing
+// newline editors and echo add
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	defer watcher.Close()
-
-	// Watch the directory, not the file (Kubernetes uses symlinks)
-	configDir := filepath.Dir(configPath)
-	err = watcher.Add(configDir)
-	if err != nil {
-		log.Fatal(err)
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("%s is empty", path)
 	}
+	return secret, nil
+}
 
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-			// Kubernetes updates ConfigMaps by updating symlinks
-			if event.Op&fsnotify.Create == fsnotify.Create ||
-				event.Op&fsnotify.Write == fsnotify.Write {
-				log.Println("Config file changed, reloading...")
-				config, err := loadConfig(configPath)
-				if err != nil {
-					log.Printf("Error reloading config: %v", err)
-				}
-				handler.updateConfig(config)
-
-			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return
-			}
-			log.Println("Watcher error:", err)
-		}
+// logStartup logs the effective settings as a single event, optional
+// features are only listed when enabled. Durations are logged as strings so
+// text and JSON output read the same
+func logStartup(appConfig AppConfig, watch bool) {
+	attrs := []any{
+		"version", version,
+		"config", appConfig.ConfigFile,
+		"addr", appConfig.BindAddr,
+		"port", appConfig.BindPort,
+		"watch", watch,
+		"log_level", appConfig.LogLevel,
+		"log_extended", appConfig.LogExtended,
+		"shutdown_timeout", appConfig.ShutdownTimeout.String(),
+		"gzip_level", appConfig.GzipLevel,
+		"gzip_min_length", appConfig.GzipMinLength,
+		"h2c", appConfig.H2C,
+		"keepalive", appConfig.KeepAlive,
+		"tls", appConfig.TLSCert != "",
+	}
+	if appConfig.ReloadHook != "" {
+		attrs = append(attrs, "reload_hook", appConfig.ReloadHook)
+	}
+	if appConfig.LogSample < 1 {
+		attrs = append(attrs, "log_sample", appConfig.LogSample)
+	}
+	if appConfig.MaxConns > 0 {
+		attrs = append(attrs, "max_conns", appConfig.MaxConns)
+	}
+	if appConfig.IdleTimeout > 0 {
+		attrs = append(attrs, "idle_timeout", appConfig.IdleTimeout.String())
+	}
+	if appConfig.RobotsFile != "" {
+		attrs = append(attrs, "robots", appConfig.RobotsFile)
+	}
+	if appConfig.TemplatesDir != "" {
+		attrs = append(attrs, "templates", appConfig.TemplatesDir)
+	}
+	if appConfig.CategoriesDir != "" {
+		attrs = append(attrs, "categories", appConfig.CategoriesDir)
+	}
+	if appConfig.ConfigFormat != "" {
+		attrs = append(attrs, "config_format", appConfig.ConfigFormat)
+	}
+	if appConfig.Strict {
+		attrs = append(attrs, "strict", true)
+	}
+	if appConfig.Dedupe {
+		attrs = append(attrs, "dedupe", true)
+	}
+	if appConfig.ReadOnly {
+		attrs = append(attrs, "read_only", true)
+	}
+	if appConfig.Featured {
+		attrs = append(attrs, "featured", true)
+	}
+	if appConfig.PWA {
+		attrs = append(attrs, "pwa", true)
+	}
+	if appConfig.Whoami {
+		attrs = append(attrs, "whoami", true)
+	}
+	if appConfig.ProfileRender {
+		attrs = append(attrs, "profile_render", true)
+	}
+	if appConfig.OTelEndpoint != "" {
+		attrs = append(attrs, "otel_endpoint", appConfig.OTelEndpoint)
+	}
+	if appConfig.RenderCacheTTL > 0 {
+		attrs = append(attrs, "render_cache_ttl", appConfig.RenderCacheTTL.String())
+	}
+	if appConfig.TLSCert != "" {
+		attrs = append(attrs, "tls_min_version", appConfig.TLSMinVersion)
+	}
+	if appConfig.TLSCiphers != "" {
+		attrs = append(attrs, "tls_ciphers", appConfig.TLSCiphers)
+	}
+	if appConfig.ClientCA != "" {
+		attrs = append(attrs, "client_ca", appConfig.ClientCA)
+	}
+	if appConfig.CheckInterval > 0 {
+		attrs = append(attrs, "check_interval", appConfig.CheckInterval.String(), "check_stale", appConfig.CheckStale.String())
+	}
+	if appConfig.StateDir != "" {
+		attrs = append(attrs, "state_dir", appConfig.StateDir)
+	}
+	if appConfig.Jitter > 0 {
+		attrs = append(attrs, "jitter", appConfig.Jitter)
+	}
+	if appConfig.AuthPass != "" {
+		attrs = append(attrs, "admin_user", appConfig.AuthUser)
+	}
+	if appConfig.Favicons {
+		attrs = append(attrs, "favicon_cache_dir", appConfig.FaviconCacheDir, "file_mode", fmt.Sprintf("%#o", appConfig.FileMode), "favicon_ttl", appConfig.FaviconTTL.String(), "favicon_concurrency", appConfig.FaviconWorkers)
 	}
+	if appConfig.Descriptions {
+		attrs = append(attrs, "descriptions", true)
+	}
+	if appConfig.FetchConcurrency > 0 {
+		attrs = append(attrs, "fetch_concurrency", appConfig.FetchConcurrency)
+	}
+	attrs = append(attrs,
+		"fetch_timeout", appConfig.FetchTimeout.String(),
+		"fetch_dial_timeout", appConfig.DialTimeout.String(),
+		"fetch_tls_timeout", appConfig.TLSTimeout.String(),
+		"fetch_header_timeout", appConfig.HeaderTimeout.String(),
+	)
+	slog.Info("Starting", attrs...)
 }
 
 func main() {
 
 	// Parse command-line flags
 	appConfig := parseFlags()
+	if appConfig.Version {
+		info := currentBuildInfo()
+		fmt.Printf("home %s (commit %s, built %s, %s)\n", info.Version, info.Commit, info.Date, info.GoVersion)
+		return
+	}
+
+	slog.SetDefault(newLogger(os.Stderr, appConfig.LogFormat, appConfig.LogLevel))
 
-	// Display configuration
-	log.Printf("Starting with configuration:")
-	log.Printf("  Config file: %s", appConfig.ConfigFile)
-	log.Printf("  Bind address: %s", appConfig.BindAddr)
-	log.Printf("  Port: %d", appConfig.BindPort)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Check if config file exists
-	if _, err := os.Stat(appConfig.ConfigFile); os.IsNotExist(err) {
-		log.Fatalf("Configuration file not found: %s", appConfig.ConfigFile)
+	remote := isRemoteConfig(appConfig.ConfigFile)
+	pattern := isConfigPattern(appConfig.ConfigFile)
+	// stdin can only be read once, so there is nothing to watch or edit
+	stdin := appConfig.ConfigFile == stdinConfig
+	missing := isMissingConfig(appConfig.ConfigFile)
+	// A config file on disk wins over the one built into the binary, which
+	// can't be watched or edited either
+	embedded := missing && embeddedConfig != nil
+	if embedded {
+		slog.Info("Configuration file not found, using the embedded one", "path", appConfig.ConfigFile)
+		appConfig.ConfigFile = embeddedConfigSource
 	}
-	config, err := loadConfig(appConfig.ConfigFile)
+	watch := watchSupported && !remote && !stdin && !embedded
+	logStartup(appConfig, watch)
+	if missing && !embedded {
+		fatal("Configuration file not found", "path", appConfig.ConfigFile)
+	}
+	client := newFetchClient(fetchTimeouts{
+		total:  appConfig.FetchTimeout,
+		dial:   appConfig.DialTimeout,
+		tls:    appConfig.TLSTimeout,
+		header: appConfig.HeaderTimeout,
+	})
+	load := loadOptions{
+		categoriesDir: appConfig.CategoriesDir,
+		format:        appConfig.ConfigFormat,
+		client:        client,
+		strict:        appConfig.Strict,
+		dedupe:        appConfig.Dedupe,
+		csvColumns:    appConfig.CSVColumns,
+	}
+	result, err := loadConfigContext(ctx, appConfig.ConfigFile, load)
 	if err != nil {
-		log.Fatal(err)
+		fatal("Failed to load configuration", "error", err)
 	}
+	config := result.Config
 
-	handler, err := NewHandler(config)
+	handler, err := NewHandler(config, appConfig.TemplatesDir)
 	if err != nil {
-		log.Fatal(err)
+		fatal("Failed to create handler", "error", err)
+	}
+	handler.configFile = appConfig.ConfigFile
+	handler.load = load
+	handler.featured = appConfig.Featured
+	handler.pwa = appConfig.PWA
+	handler.profileRender = appConfig.ProfileRender
+	handler.reloadHook = appConfig.ReloadHook
+	if appConfig.OTelEndpoint != "" {
+		handler.tracer = newTracer(appConfig.OTelEndpoint, client)
+		go handler.tracer.run(ctx)
+	}
+	if appConfig.RenderCacheTTL > 0 {
+		handler.rendered = newRenderCache(appConfig.RenderCacheTTL)
+	}
+	if appConfig.Shuffle {
+		rng, seed := newShuffleRand(appConfig.ShuffleSeed)
+		slog.Info("Shuffling links", "seed", seed)
+		handler.shuffle = rng
 	}
+	handler.updateConfig(result)
 
-	go watchConfig(appConfig.ConfigFile, handler)
+	if appConfig.AuthPass != "" {
+		if remote || pattern || stdin || embedded {
+			slog.Warn("Admin page disabled: it can only edit a single local config file")
+		} else if configFormat(appConfig.ConfigFile, appConfig.ConfigFormat) != formatYAML {
+			slog.Warn("Admin page disabled: it can only edit YAML config files")
+		} else {
+			handler.editor = &configEditor{path: appConfig.ConfigFile, load: load}
+		}
+	}
+
+	routes, err := newRoutes(handler, appConfig)
+	if err != nil {
+		fatal("Failed to set up routes", "error", err)
+	}
+	if appConfig.PrintRoutes {
+		printRoutes(os.Stdout, routes)
+		return
+	}
+	mux := newMux(routes)
+
+	tlsConfig, err := newTLSConfig(appConfig)
+	if err != nil {
+		fatal("Failed to set up TLS", "error", err)
+	}
 
-	log.Println("Server starting on :8080")
-	http.HandleFunc("/", handler.index)
 	bindAddress := fmt.Sprintf("%s:%d", appConfig.BindAddr, appConfig.BindPort)
-	if err := http.ListenAndServe(bindAddress, nil); err != nil {
-		log.Fatal(err)
+	if appConfig.Probe {
+		if err := probe(bindAddress); err != nil {
+			fatal("Probe failed", "error", err)
+		}
+		slog.Info("Probe succeeded: configuration loaded and address is bindable", "addr", bindAddress)
+		return
+	}
+
+	// The handler is fully set up before the background goroutines start
+	// reading it
+	limiter := newFetchLimiter(appConfig.FetchConcurrency)
+	var state *stateStore
+	if appConfig.CheckInterval > 0 {
+		handler.health = newHealthChecker(appConfig.CheckInterval, appConfig.CheckStale, appConfig.HealthThreshold, appConfig.Jitter, client, limiter)
+		if appConfig.StateDir != "" {
+			if state, err = newStateStore(appConfig.StateDir, appConfig.FileMode); err != nil {
+				fatal("Failed to set up the state dir", "error", err)
+			}
+			state.restore(handler.health)
+		}
+	} else if appConfig.StateDir != "" {
+		slog.Warn("-state-dir has nothing to save without -check-interval")
+	}
+	if appConfig.Favicons {
+		if handler.favicons, err = newFaviconCache(appConfig.FaviconCacheDir, appConfig.FaviconTTL, appConfig.FileMode, client, limiter); err != nil {
+			fatal("Failed to set up favicons", "error", err)
+		}
+	}
+	handler.imports = newSourceImporter(appConfig.Jitter, client, limiter)
+	if appConfig.Descriptions {
+		if handler.descriptions, err = newDescriptionCache(appConfig.FaviconCacheDir, appConfig.FaviconTTL, appConfig.FileMode, appConfig.Jitter, client, limiter); err != nil {
+			fatal("Failed to set up descriptions", "error", err)
+		}
+	}
+
+	go handler.imports.run(ctx, handler)
+	if handler.health != nil {
+		if state != nil {
+			go state.run(ctx, handler.health)
+		}
+		go handler.health.run(ctx, handler)
+	}
+	if handler.favicons != nil {
+		go handler.favicons.warm(ctx, config, appConfig.FaviconWorkers)
+	}
+	if handler.descriptions != nil {
+		go handler.descriptions.run(ctx, handler)
+	}
+
+	if watch {
+		go watchConfig(appConfig.ConfigFile, handler)
+	}
+
+	slog.Info("Server starting", "addr", bindAddress)
+	var root http.Handler = logRequests(handler.tracer.traceRequests(gzipResponses(mux, appConfig.GzipLevel, appConfig.GzipMinLength)), appConfig.LogExtended, appConfig.LogSample)
+	if appConfig.H2C {
+		// Cleartext HTTP/2 for load balancers that speak h2c, HTTP/1.1
+		// requests are still served as usual
+		root = h2c.NewHandler(root, &http2.Server{})
+	}
+	server := newServer(appConfig, bindAddress, root, tlsConfig)
+
+	listener, err := net.Listen("tcp", bindAddress)
+	if err != nil {
+		fatal("Failed to listen", "addr", bindAddress, "error", err)
+	}
+	if appConfig.MaxConns > 0 {
+		// Connections over the limit wait in Accept until a slot frees up
+		listener = netutil.LimitListener(listener, appConfig.MaxConns)
+	}
+
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			// The certificate is already in TLSConfig
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("Server failed", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	shutdown(server, appConfig.ShutdownTimeout)
+	if state != nil {
+		state.flush(handler.health)
+	}
+}
+
+// runReloadHook executes hook after a successful reload. The link count is
+// passed both as the last argument and in the LINK_COUNT environment
+// variable. Failures are only logged, a broken hook must not take the
+// server down.
+func runReloadHook(hook string, linkCount int) {
+	fields := strings.Fields(hook)
+	if len(fields) == 0 {
+		return
+	}
+	count := strconv.Itoa(linkCount)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reloadHookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, fields[0], append(fields[1:], count)...)
+	cmd.Env = append(os.Environ(), "LINK_COUNT="+count)
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		slog.Info("Reload hook output", "output", strings.TrimSpace(string(output)))
+	}
+	if err != nil {
+		slog.Error("Reload hook failed", "error", err)
+	}
+}
+
+// newLogger returns a logger writing records at level and above to w, in
+// the -log-format format
+func newLogger(w io.Writer, format string, level slog.Level) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// fatal logs msg at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// probe checks that the server could bind to address by opening a
+// listener and closing it straight away
+func probe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return listener.Close()
+}
+
+// newServer returns the HTTP server for handler with the connection
+// settings of appConfig
+func newServer(appConfig AppConfig, address string, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	server := &http.Server{
+		Addr:        address,
+		Handler:     handler,
+		TLSConfig:   tlsConfig,
+		IdleTimeout: appConfig.IdleTimeout,
+	}
+	server.SetKeepAlivesEnabled(appConfig.KeepAlive)
+	return server
+}
+
+// shutdown stops the server gracefully, giving in-flight requests up to
+// timeout to complete before the remaining connections are force-closed
+func shutdown(server *http.Server, timeout time.Duration) {
+	slog.Info("Shutting down, waiting for in-flight requests", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("Shutdown timeout exceeded, dropping in-flight requests", "error", err)
+		server.Close()
 	}
 }
@@ -0,0 +1,711 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestHandler returns a handler serving config with the embedded
+// templates
+func newTestHandler(t *testing.T, config Configuration) *Handler {
+	t.Helper()
+	h, err := NewHandler(config, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// get serves a GET request for target and returns the recorded response
+func get(t *testing.T, handler http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+// renderIndex returns the body of the main page of config
+func renderIndex(t *testing.T, config Configuration) string {
+	t.Helper()
+	rec := get(t, http.HandlerFunc(newTestHandler(t, config).index), "/")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET / = %d: %s", rec.Code, rec.Body)
+	}
+	return rec.Body.String()
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		n    int
+		s    string
+		want string
+	}{
+		{10, "short", "short"},
+		{5, "exact", "exact"},
+		{5, "too long", "too …"},
+		{0, "no limit", "no limit"},
+		{-1, "no limit", "no limit"},
+		{4, "héllo wörld", "hél…"},
+		{3, "日本語テキスト", "日本…"},
+		{2, "👋👋👋", "👋…"},
+		{1, "ab", "…"},
+		{3, "", ""},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.n, tt.s); got != tt.want {
+			t.Errorf("truncate(%d, %q) = %q, want %q", tt.n, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestDescriptionIsTruncated(t *testing.T) {
+	description := strings.Repeat("é", 100)
+	body := renderIndex(t, Configuration{Links: []Link{{Name: "Long", Url: "http://long.local", Description: description}}})
+	if !strings.Contains(body, `title="`+description+`"`) {
+		t.Error("the full description is not in the title attribute")
+	}
+	if want := ">" + strings.Repeat("é", 79) + "…</p>"; !strings.Contains(body, want) {
+		t.Errorf("the page has no description truncated to 80 characters")
+	}
+}
+
+func TestShutdownForceClosesSlowRequests(t *testing.T) {
+	buf := captureLog(t, slog.LevelInfo)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+	}))
+	defer server.Close()
+	// Close waits for the handler, which has to be released first
+	defer close(release)
+
+	errs := make(chan error, 1)
+	go func() {
+		resp, err := server.Client().Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		errs <- err
+	}()
+	<-started
+
+	begin := time.Now()
+	shutdown(server.Config, 50*time.Millisecond)
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Errorf("shutdown took %v with a 50ms timeout", elapsed)
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("the in-flight request completed, want its connection closed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the in-flight request is still running after shutdown")
+	}
+	if !strings.Contains(buf.String(), "dropping in-flight requests") {
+		t.Errorf("log output = %q, want the dropped requests logged", buf.String())
+	}
+}
+
+func TestShutdownWaitsForFastRequests(t *testing.T) {
+	buf := captureLog(t, slog.LevelInfo)
+	server := httptest.NewServer(http.HandlerFunc(servePing))
+	defer server.Close()
+	shutdown(server.Config, time.Second)
+	if strings.Contains(buf.String(), "dropping") {
+		t.Errorf("log output = %q, want a clean shutdown", buf.String())
+	}
+}
+
+func TestProbe(t *testing.T) {
+	if err := probe("127.0.0.1:0"); err != nil {
+		t.Errorf("probe of a free port: %v", err)
+	}
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	if err := probe(busy.Addr().String()); err == nil {
+		t.Errorf("probe of %s succeeded while the port is in use", busy.Addr())
+	}
+	if err := probe("256.0.0.1:80"); err == nil {
+		t.Error("probe of an invalid address succeeded")
+	}
+}
+
+// writeFile writes content to name in dir and returns its path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReloadRunsHook(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "hook.out")
+	hook := writeFile(t, dir, "hook.sh", "#!/bin/sh\necho \"$1 $LINK_COUNT\" > "+out+".tmp && mv "+out+".tmp "+out+"\n")
+	if err := os.Chmod(hook, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := writeFile(t, dir, "config.yaml", "links:\n  - {name: A, url: http://a.local}\n  - {name: B, url: http://b.local}\ngroups:\n  - name: G\n    links:\n      - {name: C, url: http://c.local}\n")
+
+	h := newTestHandler(t, Configuration{})
+	h.reloadHook = hook
+	if _, _, err := h.reload(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(out)
+		if err == nil {
+			if got := strings.TrimSpace(string(data)); got != "3 3" {
+				t.Errorf("hook got argument and LINK_COUNT %q, want \"3 3\"", got)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the hook did not run after the reload")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunReloadHookFailureIsLogged(t *testing.T) {
+	buf := captureLog(t, slog.LevelInfo)
+	runReloadHook("false", 1)
+	runReloadHook(filepath.Join(t.TempDir(), "missing"), 1)
+	runReloadHook("   ", 1)
+	if n := strings.Count(buf.String(), "Reload hook failed"); n != 2 {
+		t.Errorf("logged %d hook failures, want 2:\n%s", n, buf)
+	}
+}
+
+func TestLoadConfigAnchorsAndMergeKeys(t *testing.T) {
+	result, err := loadConfig("testdata/anchors.yaml", loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := result.Config
+	sso := Link{Auth: true, NewTab: true, Description: "Behind SSO", Tags: []string{"infra", "sso"}}
+	withName := func(l Link, name, url string) Link {
+		l.Name, l.Url = name, url
+		return l
+	}
+
+	wiki := withName(sso, "Wiki", "http://wiki.local")
+	wiki.Description = "Team notes"
+	wiki.NewTab = false
+	wantLinks := []Link{
+		withName(sso, "Grafana", "http://grafana.local"),
+		wiki,
+		{Name: "Status", Url: "http://status.local", Tags: []string{"infra", "sso"}},
+	}
+	if !reflect.DeepEqual(config.Links, wantLinks) {
+		t.Errorf("links = %+v\nwant %+v", config.Links, wantLinks)
+	}
+
+	wantGroups := []Group{
+		{Name: "Media", Span: 2, OpenAll: true, Links: []Link{withName(sso, "Jellyfin", "http://jellyfin.local")}},
+		{Name: "Music", Span: 1, OpenAll: true},
+	}
+	if !reflect.DeepEqual(config.Groups, wantGroups) {
+		t.Errorf("groups = %+v\nwant %+v", config.Groups, wantGroups)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	config := writeFile(t, t.TempDir(), "config.yaml", "links:\n  - {name: A, url: http://a.local}\n")
+	tests := []struct {
+		level       slog.Level
+		wantDebug   bool
+		wantSuccess bool
+	}{
+		{slog.LevelDebug, true, true},
+		{slog.LevelInfo, false, true},
+		{slog.LevelWarn, false, false},
+		{slog.LevelError, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			for _, format := range []string{"text", "json"} {
+				var buf bytes.Buffer
+				previous := slog.Default()
+				slog.SetDefault(newLogger(&buf, format, tt.level))
+				h := newTestHandler(t, Configuration{})
+				if _, _, err := h.reload(context.Background(), config); err != nil {
+					t.Fatal(err)
+				}
+				runReloadHook("false", 1)
+				slog.SetDefault(previous)
+
+				out := buf.String()
+				if got := strings.Contains(out, "Configuration updated"); got != tt.wantDebug {
+					t.Errorf("%s: debug line logged %v, want %v:\n%s", format, got, tt.wantDebug, out)
+				}
+				if got := strings.Contains(out, "Configuration reloaded"); got != tt.wantSuccess {
+					t.Errorf("%s: reload success logged %v, want %v:\n%s", format, got, tt.wantSuccess, out)
+				}
+				if !strings.Contains(out, "Reload hook failed") {
+					t.Errorf("%s: the hook error is missing:\n%s", format, out)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigContextCancelAbortsRemoteLoad(t *testing.T) {
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		select {
+		case <-req.Context().Done():
+		case <-time.After(10 * time.Second):
+			io.WriteString(w, "links:\n  - {name: Late, url: http://late.local}\n")
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	begin := time.Now()
+	_, err := loadConfigContext(ctx, server.URL+"/config.yaml", loadOptions{client: server.Client()})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("loadConfigContext error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Errorf("the load took %v after the context was cancelled", elapsed)
+	}
+}
+
+func TestLoadConfigRemote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/config.yaml" {
+			http.NotFound(w, req)
+			return
+		}
+		io.WriteString(w, "links:\n  - {name: Remote, url: http://remote.local}\n")
+	}))
+	defer server.Close()
+
+	result, err := loadConfig(server.URL+"/config.yaml", loadOptions{client: server.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := linkNames(result.Config.Links); !reflect.DeepEqual(got, []string{"Remote"}) {
+		t.Errorf("links = %v, want [Remote]", got)
+	}
+	if _, err := loadConfig(server.URL+"/missing.yaml", loadOptions{client: server.Client()}); err == nil {
+		t.Error("loadConfig accepted a 404 response")
+	}
+}
+
+func TestSpan(t *testing.T) {
+	tests := []struct {
+		n, columns, want int
+	}{
+		{2, 3, 2},
+		{3, 3, 3},
+		{5, 3, 3},
+		{0, 3, 1},
+		{-2, 3, 1},
+	}
+	for _, tt := range tests {
+		if got := span(tt.n, tt.columns); got != tt.want {
+			t.Errorf("span(%d, %d) = %d, want %d", tt.n, tt.columns, got, tt.want)
+		}
+	}
+}
+
+func TestGroupSpanIsRendered(t *testing.T) {
+	groups := []Group{
+		{Name: "Wide", Span: 2, Links: []Link{{Name: "A", Url: "http://a.local"}}},
+		{Name: "Too wide", Span: 5, Links: []Link{{Name: "B", Url: "http://b.local"}}},
+		{Name: "Default", Links: []Link{{Name: "C", Url: "http://c.local"}}},
+	}
+	body := renderIndex(t, Configuration{Columns: 3, Groups: groups})
+	for _, want := range []string{
+		`<section class="group span-2" style="grid-column: span 2" aria-labelledby="group-0">`,
+		`<section class="group span-3" style="grid-column: span 3" aria-labelledby="group-1">`,
+		`<section class="group span-1" style="grid-column: span 1" aria-labelledby="group-2">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("the page has no %s", want)
+		}
+	}
+
+	body = renderIndex(t, Configuration{Groups: groups})
+	if strings.Contains(body, "span-") || strings.Contains(body, "grid-column") {
+		t.Error("groups have a span without columns")
+	}
+}
+
+func TestReloadLogsConfigHash(t *testing.T) {
+	const fixture = "testdata/links.yaml"
+	data, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	want := hex.EncodeToString(sum[:])
+
+	buf := captureLog(t, slog.LevelInfo)
+	h := newTestHandler(t, Configuration{})
+	if _, _, err := h.reload(context.Background(), fixture); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "msg=\"Configuration reloaded\" links=3 sha256="+want) {
+		t.Errorf("log output does not have the reload with sha256=%s:\n%s", want, buf)
+	}
+	if h.getConfig().Hash != want {
+		t.Errorf("config hash = %s, want %s", h.getConfig().Hash, want)
+	}
+}
+
+func TestLinkRel(t *testing.T) {
+	tests := []struct {
+		name string
+		link Link
+		want string
+	}{
+		{"custom", Link{Name: "Docs", Url: "http://docs.local", Rel: "nofollow"}, `<a href="http://docs.local" rel="nofollow">`},
+		{"custom in a new tab", Link{Name: "Docs", Url: "http://docs.local", NewTab: true, Rel: "noopener"}, `<a href="http://docs.local" target="_blank" rel="noopener">`},
+		{"new tab default", Link{Name: "Docs", Url: "http://docs.local", NewTab: true}, `<a href="http://docs.local" target="_blank" rel="noopener noreferrer">`},
+		{"same tab", Link{Name: "Docs", Url: "http://docs.local"}, `<a href="http://docs.local">`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if body := renderIndex(t, Configuration{Links: []Link{tt.link}}); !strings.Contains(body, tt.want) {
+				t.Errorf("the page has no %s", tt.want)
+			}
+		})
+	}
+}
+
+func TestSingleUnnamedGroupHasNoHeader(t *testing.T) {
+	links := []Link{{Name: "A", Url: "http://a.local"}}
+	body := renderIndex(t, Configuration{Groups: []Group{{Links: links}}})
+	if strings.Contains(body, "<h2") || strings.Contains(body, "aria-labelledby=\"group-") {
+		t.Error("a single unnamed group has a header")
+	}
+	if !strings.Contains(body, `href="http://a.local"`) {
+		t.Error("the links of the unnamed group are missing")
+	}
+
+	body = renderIndex(t, Configuration{Groups: []Group{{Name: "Media", Links: links}}})
+	if !strings.Contains(body, `<h2 id="group-0">Media</h2>`) {
+		t.Error("a single named group has no header")
+	}
+}
+
+func TestEmptyState(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Configuration
+		empty  bool
+	}{
+		{"no links", Configuration{}, true},
+		{"empty group", Configuration{Groups: []Group{{Name: "Media"}}}, true},
+		{"top-level link", Configuration{Links: []Link{{Name: "A", Url: "http://a.local"}}}, false},
+		{"grouped link", Configuration{Groups: []Group{{Name: "Media", Links: []Link{{Name: "A", Url: "http://a.local"}}}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, tt.config)
+			h.configFile = "/etc/home/links.yaml"
+			body := get(t, http.HandlerFunc(h.index), "/").Body.String()
+			if got := strings.Contains(body, `<div class="empty">`); got != tt.empty {
+				t.Errorf("empty state shown %v, want %v", got, tt.empty)
+			}
+			if got := strings.Contains(body, "<code>/etc/home/links.yaml</code>"); got != tt.empty {
+				t.Errorf("config path shown %v, want %v", got, tt.empty)
+			}
+			if got := strings.Contains(body, `id="search"`); got == tt.empty {
+				t.Errorf("search box shown %v, want %v", got, !tt.empty)
+			}
+		})
+	}
+}
+
+func TestIndexHead(t *testing.T) {
+	h := newTestHandler(t, Configuration{Links: []Link{{Name: "A", Url: "http://a.local"}}, Hash: "abc123"})
+	index := http.HandlerFunc(h.index)
+
+	head := httptest.NewRecorder()
+	index.ServeHTTP(head, httptest.NewRequest(http.MethodHead, "/", nil))
+	if head.Code != http.StatusOK {
+		t.Errorf("HEAD / = %d, want 200", head.Code)
+	}
+	if head.Body.Len() != 0 {
+		t.Errorf("HEAD / has a %d byte body, want none", head.Body.Len())
+	}
+
+	getRec := get(t, index, "/")
+	for _, header := range []string{"Content-Type", "X-Config-Hash"} {
+		if got, want := head.Header().Get(header), getRec.Header().Get(header); got != want || got == "" {
+			t.Errorf("HEAD %s = %q, want %q as for GET", header, got, want)
+		}
+	}
+	if head.Header().Get("X-Config-Hash") != "abc123" {
+		t.Errorf("X-Config-Hash = %q, want abc123", head.Header().Get("X-Config-Hash"))
+	}
+}
+
+func TestReloadSwapsTemplateAndConfigTogether(t *testing.T) {
+	revision := func(name string) (LoadResult, *template.Template) {
+		tmpl := template.Must(template.New("links.html").Parse(name + ":{{.Title}}"))
+		return LoadResult{Config: Configuration{Title: name, Hash: name}}, tmpl
+	}
+	resultA, tmplA := revision("A")
+	resultB, tmplB := revision("B")
+	h := newTestHandler(t, resultA.Config)
+	h.update(resultA, tmplA)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				h.update(resultB, tmplB)
+			} else {
+				h.update(resultA, tmplA)
+			}
+		}
+	}()
+
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 2000 {
+				config, tmpl := h.snapshot()
+				var buf strings.Builder
+				if err := tmpl.ExecuteTemplate(&buf, "links.html", config); err != nil {
+					t.Error(err)
+					return
+				}
+				if got := buf.String(); got != "A:A" && got != "B:B" {
+					t.Errorf("rendered %q, a template and a configuration of different reloads", got)
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+	wg.Wait()
+}
+
+func TestNewHandlerFallsBackOnBrokenTemplates(t *testing.T) {
+	buf := captureLog(t, slog.LevelInfo)
+	dir := t.TempDir()
+	writeFile(t, dir, "links.html", "{{if .Title}}unterminated")
+
+	h, err := NewHandler(Configuration{Title: "Home"}, dir)
+	if err != nil {
+		t.Fatalf("NewHandler with a broken template: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Falling back to the embedded templates") {
+		t.Errorf("the broken template was not logged:\n%s", buf)
+	}
+	rec := get(t, http.HandlerFunc(h.index), "/")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<h1>Home</h1>") {
+		t.Errorf("GET / = %d, want the page rendered with the embedded templates", rec.Code)
+	}
+}
+
+func TestNewHandlerUsesTemplatesDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "links.html", "custom {{.Title}}")
+	h, err := NewHandler(Configuration{Title: "Home"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := get(t, http.HandlerFunc(h.index), "/").Body.String(); body != "custom Home" {
+		t.Errorf("GET / = %q, want the template of the directory", body)
+	}
+}
+
+func TestHomeURLHeading(t *testing.T) {
+	body := renderIndex(t, Configuration{Title: "Home", HomeURL: "http://router.local"})
+	if want := `<h1><a class="home" href="http://router.local">Home</a></h1>`; !strings.Contains(body, want) {
+		t.Errorf("the page has no %s", want)
+	}
+	body = renderIndex(t, Configuration{Title: "Home"})
+	if !strings.Contains(body, "<h1>Home</h1>") || strings.Contains(body, `class="home"`) {
+		t.Error("without home_url the heading is not plain text")
+	}
+}
+
+func TestPreviewTrigger(t *testing.T) {
+	body := renderIndex(t, Configuration{Links: []Link{
+		{Name: "Grafana", Url: "http://grafana.local", Preview: true},
+		{Name: "Wiki", Url: "http://wiki.local"},
+	}})
+	if !strings.Contains(body, `<button type="button" class="copy preview" data-url="http://grafana.local" aria-label="Preview Grafana" title="Preview">preview</button>`) {
+		t.Errorf("no preview trigger for Grafana in\n%s", body)
+	}
+	if strings.Contains(body, `aria-label="Preview Wiki"`) {
+		t.Error("Wiki has a preview trigger without preview: true")
+	}
+	if !strings.Contains(body, `<dialog class="preview-frame" id="preview"`) {
+		t.Error("the page has no preview dialog")
+	}
+}
+
+func TestPreviewBlockedByFrameHeaders(t *testing.T) {
+	tests := map[string]http.Header{
+		"X-Frame-Options":     {"X-Frame-Options": {"SAMEORIGIN"}},
+		"CSP frame-ancestors": {"Content-Security-Policy": {"default-src 'self'; frame-ancestors 'none'"}},
+	}
+	for name, header := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				for key, values := range header {
+					w.Header()[key] = values
+				}
+			}))
+			defer server.Close()
+			config := Configuration{Links: []Link{{Name: "Grafana", Url: server.URL, Preview: true}}}
+			h := checkedHandler(t, config)
+			body := get(t, http.HandlerFunc(h.index), "/").Body.String()
+			if !strings.Contains(body, `aria-label="Preview Grafana" disabled title="This site doesn't allow being shown in a frame"`) {
+				t.Errorf("the preview trigger is not disabled in\n%s", body)
+			}
+		})
+	}
+}
+
+func TestLinkClass(t *testing.T) {
+	body := renderIndex(t, Configuration{Links: []Link{
+		{Name: "Grafana", Url: "http://grafana.local", Class: "ops highlight_1"},
+		{Name: "Wiki", Url: "http://wiki.local"},
+	}})
+	if !strings.Contains(body, `<a href="http://grafana.local" class="ops highlight_1">Grafana</a>`) {
+		t.Errorf("the class is not on the Grafana anchor in\n%s", body)
+	}
+	if !strings.Contains(body, `<a href="http://wiki.local">Wiki</a>`) {
+		t.Error("a link without a class has a class attribute")
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	for _, keepAlive := range []bool{true, false} {
+		server := httptest.NewUnstartedServer(nil)
+		server.Config = newServer(AppConfig{KeepAlive: keepAlive}, "", http.HandlerFunc(servePing), nil)
+		server.Start()
+
+		resp, err := server.Client().Get(server.URL + "/ping")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		server.Close()
+		// resp.Close is set from the Connection: close header
+		if resp.Close == keepAlive {
+			t.Errorf("-keepalive=%v: Connection: close sent = %v", keepAlive, resp.Close)
+		}
+	}
+}
+
+func TestNewServerIdleTimeout(t *testing.T) {
+	server := newServer(AppConfig{KeepAlive: true, IdleTimeout: 90 * time.Second}, ":8080", http.NotFoundHandler(), nil)
+	if server.IdleTimeout != 90*time.Second || server.Addr != ":8080" {
+		t.Errorf("server = %s with idle timeout %s", server.Addr, server.IdleTimeout)
+	}
+}
+
+func TestReadSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content string
+		want    string
+		wantErr bool
+	}{
+		{content: "s3cret\n", want: "s3cret"},
+		{content: "s3cret\r\n", want: "s3cret"},
+		{content: "s3cret", want: "s3cret"},
+		{content: " spaced pass \n\n", want: " spaced pass "},
+		{content: "\n", wantErr: true},
+	}
+	for i, tt := range tests {
+		got, err := readSecretFile(writeFile(t, dir, fmt.Sprintf("secret%d", i), tt.content))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("readSecretFile(%q) = %q, %v, want %q", tt.content, got, err, tt.want)
+		}
+	}
+	if _, err := readSecretFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("reading a missing secret file succeeded")
+	}
+}
+
+func TestSecretFileCredentials(t *testing.T) {
+	dir := t.TempDir()
+	pass, err := readSecretFile(writeFile(t, dir, "pass", "from-file\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := readSecretFile(writeFile(t, dir, "token", "token-from-file\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := newEditingHandler(t, "links: []\n")
+	mux := newTestMux(t, h, AppConfig{AuthUser: "admin", AuthPass: pass, ReloadToken: token})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/links", nil)
+	req.SetBasicAuth("admin", "from-file")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /api/links with the password from the file = %d, want 200", rec.Code)
+	}
+	req.SetBasicAuth("admin", "from-file\n")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /api/links with the trailing newline = %d, want 401", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/-/diff", nil)
+	req.Header.Set("Authorization", "Bearer token-from-file")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /-/diff with the token from the file = %d, want 200", rec.Code)
+	}
+}
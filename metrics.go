@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// reloadBuckets are the upper bounds in seconds of the reload duration
+// histogram, the Prometheus client defaults
+var reloadBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metrics holds the values exposed on /metrics in the Prometheus text
+// format. There are few enough of them that they are written by hand
+// rather than pulling in the client library.
+type metrics struct {
+	mu sync.Mutex
+	// reloadCounts counts the reloads that took at most the matching
+	// reloadBuckets bound and more than the previous one
+	reloadCounts   []uint64
+	reloadSum      float64
+	reloadCount    uint64
+	reloadFailures uint64
+	lastReload     time.Time
+	links          int
+	// renderHits and renderMisses count the pages served from the render
+	// cache and rendered again while it is enabled
+	renderHits   uint64
+	renderMisses uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{reloadCounts: make([]uint64, len(reloadBuckets))}
+}
+
+// observeReload records a reload that took d, err tells whether it failed
+func (m *metrics) observeReload(d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.reloadFailures++
+		return
+	}
+	seconds := d.Seconds()
+	for i, bound := range reloadBuckets {
+		if seconds <= bound {
+			m.reloadCounts[i]++
+			break
+		}
+	}
+	m.reloadSum += seconds
+	m.reloadCount++
+	m.lastReload = time.Now()
+}
+
+// setLinks records the number of links of the configuration being served
+func (m *metrics) setLinks(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.links = n
+}
+
+// observeRenderCache records whether a page was served from the render
+// cache
+func (m *metrics) observeRenderCache(hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if hit {
+		m.renderHits++
+	} else {
+		m.renderMisses++
+	}
+}
+
+// write renders the metrics in the Prometheus text exposition format
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP config_reload_duration_seconds Time taken by successful configuration reloads.")
+	fmt.Fprintln(w, "# TYPE config_reload_duration_seconds histogram")
+	var cumulative uint64
+	for i, bound := range reloadBuckets {
+		cumulative += m.reloadCounts[i]
+		fmt.Fprintf(w, "config_reload_duration_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "config_reload_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.reloadCount)
+	fmt.Fprintf(w, "config_reload_duration_seconds_sum %g\n", m.reloadSum)
+	fmt.Fprintf(w, "config_reload_duration_seconds_count %d\n", m.reloadCount)
+
+	fmt.Fprintln(w, "# HELP config_reloads_total Configuration reloads by result.")
+	fmt.Fprintln(w, "# TYPE config_reloads_total counter")
+	fmt.Fprintf(w, "config_reloads_total{result=\"success\"} %d\n", m.reloadCount)
+	fmt.Fprintf(w, "config_reloads_total{result=\"failure\"} %d\n", m.reloadFailures)
+
+	fmt.Fprintln(w, "# HELP config_last_reload_timestamp Unix time of the last successful configuration load.")
+	fmt.Fprintln(w, "# TYPE config_last_reload_timestamp gauge")
+	var last float64
+	if !m.lastReload.IsZero() {
+		last = float64(m.lastReload.UnixMilli()) / 1000
+	}
+	fmt.Fprintf(w, "config_last_reload_timestamp %g\n", last)
+
+	fmt.Fprintln(w, "# HELP config_links_total Number of links in the configuration being served.")
+	fmt.Fprintln(w, "# TYPE config_links_total gauge")
+	fmt.Fprintf(w, "config_links_total %d\n", m.links)
+
+	fmt.Fprintln(w, "# HELP render_cache_requests_total Page requests by render cache result.")
+	fmt.Fprintln(w, "# TYPE render_cache_requests_total counter")
+	fmt.Fprintf(w, "render_cache_requests_total{result=\"hit\"} %d\n", m.renderHits)
+	fmt.Fprintf(w, "render_cache_requests_total{result=\"miss\"} %d\n", m.renderMisses)
+}
+
+func (h *Handler) serveMetrics(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	h.metrics.write(w)
+}
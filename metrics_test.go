@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// metricValue returns the value of the metric line starting with name in
+// the /metrics output body
+func metricValue(t *testing.T, body, name string) string {
+	t.Helper()
+	for _, line := range strings.Split(body, "\n") {
+		if value, ok := strings.CutPrefix(line, name+" "); ok {
+			return value
+		}
+	}
+	t.Fatalf("no %s in\n%s", name, body)
+	return ""
+}
+
+func TestMetricsAfterReload(t *testing.T) {
+	h := newTestHandler(t, Configuration{})
+	if _, _, err := h.reload(context.Background(), "testdata/links.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := h.reload(context.Background(), "testdata/missing.yaml"); err == nil {
+		t.Fatal("reloading a missing file succeeded")
+	}
+
+	body := get(t, http.HandlerFunc(h.serveMetrics), "/metrics").Body.String()
+	if got := metricValue(t, body, "config_links_total"); got != "3" {
+		t.Errorf("config_links_total = %s, want the 3 links of the fixture", got)
+	}
+	if got := metricValue(t, body, "config_reload_duration_seconds_count"); got != "1" {
+		t.Errorf("config_reload_duration_seconds_count = %s, want 1", got)
+	}
+	if got := metricValue(t, body, `config_reload_duration_seconds_bucket{le="+Inf"}`); got != "1" {
+		t.Errorf("+Inf bucket = %s, want 1", got)
+	}
+	if got := metricValue(t, body, `config_reloads_total{result="failure"}`); got != "1" {
+		t.Errorf("failed reloads = %s, want 1", got)
+	}
+	if got := metricValue(t, body, "config_last_reload_timestamp"); got == "0" {
+		t.Error("config_last_reload_timestamp is not set")
+	}
+}
+
+func TestReloadHistogramBuckets(t *testing.T) {
+	m := newMetrics()
+	m.observeReload(3*time.Millisecond, nil)
+	m.observeReload(200*time.Millisecond, nil)
+	m.observeReload(time.Minute, nil)
+	m.observeReload(time.Millisecond, errors.New("broken"))
+
+	var buf strings.Builder
+	m.write(&buf)
+	body := buf.String()
+	for name, want := range map[string]string{
+		`config_reload_duration_seconds_bucket{le="0.005"}`: "1",
+		`config_reload_duration_seconds_bucket{le="0.1"}`:   "1",
+		`config_reload_duration_seconds_bucket{le="0.25"}`:  "2",
+		`config_reload_duration_seconds_bucket{le="10"}`:    "2",
+		`config_reload_duration_seconds_bucket{le="+Inf"}`:  "3",
+		"config_reload_duration_seconds_count":              "3",
+	} {
+		if got := metricValue(t, body, name); got != want {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+}
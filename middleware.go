@@ -0,0 +1,273 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/subtle"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// statusRecorder captures the status code and size of a response so it
+// can be reported once the handler is done
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// sanitizeLogValue escapes CR and LF so a client can't forge log lines
+// through a header value, whatever handler formats the log
+func sanitizeLogValue(s string) string {
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(s)
+}
+
+// logRequests writes one access log line per request. When extended is
+// set the User-Agent and Referer headers are included too. Only a random
+// sample fraction of the requests answered below 400 is logged, errors
+// always are.
+func logRequests(next http.Handler, extended bool, sample float64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, req)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status < 400 && sample < 1 && rand.Float64() >= sample {
+			return
+		}
+
+		attrs := []any{
+			"remote", req.RemoteAddr,
+			"method", req.Method,
+			"uri", req.URL.RequestURI(),
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration", time.Since(start),
+		}
+		if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+			attrs = append(attrs, "client_cn", sanitizeLogValue(req.TLS.PeerCertificates[0].Subject.CommonName))
+		}
+		if extended {
+			attrs = append(attrs, "ua", sanitizeLogValue(req.UserAgent()), "referer", sanitizeLogValue(req.Referer()))
+		}
+		slog.Info("request", attrs...)
+	})
+}
+
+// gzipResponseWriter holds back the response until at least minLength
+// bytes have been written, so small responses go out uncompressed
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	level     int
+	minLength int
+	status    int
+	buf       []byte
+	gz        *gzip.Writer
+	// started is set once the headers went out, compressed or not
+	started bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if w.started {
+		if w.gz != nil {
+			return w.gz.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) < w.minLength {
+		return len(b), nil
+	}
+	// Leave responses the handler already encoded itself untouched
+	if err := w.start(w.Header().Get("Content-Encoding") == ""); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// start sends the headers and the buffered bytes, compressing them and
+// everything written afterwards when compress is set
+func (w *gzipResponseWriter) start(compress bool) error {
+	w.started = true
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if !compress {
+		w.ResponseWriter.WriteHeader(status)
+		_, err := w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+		return err
+	}
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" {
+		// Sniff before compressing, the gzipped bytes would say nothing
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(status)
+
+	gz, err := gzip.NewWriterLevel(w.ResponseWriter, w.level)
+	if err != nil {
+		return err
+	}
+	w.gz = gz
+	_, err = w.gz.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// Close finishes the response, either by closing the gzip stream or by
+// sending whatever was too small to be worth compressing
+func (w *gzipResponseWriter) Close() error {
+	if !w.started {
+		return w.start(false)
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// gzipResponses compresses responses for clients that accept gzip, at the
+// given compression level. Responses shorter than minLength are sent as-is
+// since compressing them costs more CPU than it saves bandwidth.
+func gzipResponses(next http.Handler, level, minLength int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, level: level, minLength: minLength}
+		defer gw.Close()
+		next.ServeHTTP(gw, req)
+	})
+}
+
+// allowMethods restricts h to the given methods, answering anything else
+// with 405 and an Allow header. GET implies HEAD.
+func allowMethods(h http.HandlerFunc, methods ...string) http.HandlerFunc {
+	allowed := make([]string, 0, len(methods)+1)
+	for _, m := range methods {
+		allowed = append(allowed, m)
+		if m == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	allow := strings.Join(allowed, ", ")
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		for _, m := range allowed {
+			if req.Method == m {
+				h(w, req)
+				return
+			}
+		}
+		w.Header().Set("Allow", allow)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// requireAuth protects h with HTTP basic authentication
+func requireAuth(h http.HandlerFunc, user, pass string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		u, p, ok := req.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(p), []byte(pass)) == 1
+		if !ok || !userOK || !passOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="home", charset="UTF-8"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h(w, req)
+	}
+}
+
+// sameOrigin answers the requests that change something with 403 when a
+// browser sent them from another site, so a page visited while logged in
+// can't post the admin forms with the cached basic auth credentials.
+// Requests without Sec-Fetch-Site or Origin, such as curl's, pass.
+func sameOrigin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead && !isSameOrigin(req) {
+			http.Error(w, "Cross-site request rejected", http.StatusForbidden)
+			return
+		}
+		h(w, req)
+	}
+}
+
+// isSameOrigin reports whether req comes from a page of this server, or
+// not from a page at all, going by Sec-Fetch-Site and, for browsers that
+// don't send it, Origin
+func isSameOrigin(req *http.Request) bool {
+	switch req.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default:
+		return false
+	}
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == req.Host
+}
+
+// rejectWrites answers every request that isn't a GET or HEAD with 403,
+// for -read-only
+func rejectWrites(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(w, "Read-only mode: changes are disabled", http.StatusForbidden)
+			return
+		}
+		h(w, req)
+	}
+}
+
+// requireToken protects h with a bearer token in the Authorization header
+func requireToken(h http.HandlerFunc, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="home"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h(w, req)
+	}
+}
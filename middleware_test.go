@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLog sends the default logger's output at level and above to the
+// returned buffer until the test ends
+func captureLog(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: level})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestSanitizeLogValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"curl/8.0", "curl/8.0"},
+		{"evil\r\nlevel=ERROR msg=forged", `evil\r\nlevel=ERROR msg=forged`},
+		{"a\nb\rc", `a\nb\rc`},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeLogValue(tt.in); got != tt.want {
+			t.Errorf("sanitizeLogValue(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLogRequestsNeutralizesCRLF(t *testing.T) {
+	// The JSON handler escapes control characters itself, decoding the
+	// line shows what a handler printing values as-is would write
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	handler := logRequests(http.HandlerFunc(servePing), true, 1)
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("User-Agent", "ua\r\nlevel=ERROR msg=forged")
+	req.Header.Set("Referer", "http://ref.local/\nforged=1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line struct {
+		UA      string `json:"ua"`
+		Referer string `json:"referer"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log output %q is not one JSON line: %v", buf.String(), err)
+	}
+	if want := `ua\r\nlevel=ERROR msg=forged`; line.UA != want {
+		t.Errorf("logged ua = %q, want %q", line.UA, want)
+	}
+	if want := `http://ref.local/\nforged=1`; line.Referer != want {
+		t.Errorf("logged referer = %q, want %q", line.Referer, want)
+	}
+}
+
+func TestLogRequestsHeadersOnlyWhenExtended(t *testing.T) {
+	buf := captureLog(t, slog.LevelInfo)
+	handler := logRequests(http.HandlerFunc(servePing), false, 1)
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("User-Agent", "curl/8.0")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if out := buf.String(); !strings.Contains(out, "uri=/ping") || strings.Contains(out, "curl") {
+		t.Errorf("log output = %q, want the request without the User-Agent", out)
+	}
+}
+
+// gzipped serves body through gzipResponses and returns the response
+func gzipped(t *testing.T, body string, level, minLength int) *httptest.ResponseRecorder {
+	t.Helper()
+	handler := gzipResponses(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, body)
+	}), level, minLength)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGzipSkipsSmallResponses(t *testing.T) {
+	rec := gzipped(t, "tiny", 6, 1024)
+	if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("Content-Encoding = %q, want none for a 4 byte response", enc)
+	}
+	if rec.Body.String() != "tiny" {
+		t.Errorf("body = %q, want %q", rec.Body, "tiny")
+	}
+}
+
+func TestGzipLevel(t *testing.T) {
+	body := strings.Repeat("the quick brown fox jumps over the lazy dog ", 200)
+	for _, level := range []int{gzip.BestSpeed, gzip.BestCompression} {
+		rec := gzipped(t, body, level, 1024)
+		if enc := rec.Header().Get("Content-Encoding"); enc != "gzip" {
+			t.Fatalf("level %d: Content-Encoding = %q, want gzip", level, enc)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/plain" {
+			t.Errorf("level %d: Content-Type = %q, want text/plain", level, ct)
+		}
+
+		// Compression is deterministic, the same level gives the same bytes
+		var want bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&want, level)
+		io.WriteString(gz, body)
+		gz.Close()
+		if !bytes.Equal(rec.Body.Bytes(), want.Bytes()) {
+			t.Errorf("level %d: the response is not compressed at that level", level)
+		}
+
+		r, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(r)
+		if string(got) != body {
+			t.Errorf("level %d: decompressed body differs from the original", level)
+		}
+	}
+}
+
+func TestGzipWithoutAcceptEncoding(t *testing.T) {
+	handler := gzipResponses(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, strings.Repeat("x", 4096))
+	}), 6, 0)
+	rec := get(t, handler, "/")
+	if enc := rec.Header().Get("Content-Encoding"); enc != "" || rec.Body.Len() != 4096 {
+		t.Errorf("Content-Encoding %q and %d bytes, want the plain response", enc, rec.Body.Len())
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	handler := sameOrigin(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	tests := []struct {
+		name, method string
+		fetchSite    string
+		origin       string
+		want         int
+	}{
+		{"same-origin form", http.MethodPost, "same-origin", "http://home.local", http.StatusNoContent},
+		{"typed in the address bar", http.MethodPost, "none", "", http.StatusNoContent},
+		{"cross-site form", http.MethodPost, "cross-site", "http://evil.local", http.StatusForbidden},
+		{"same-site subdomain", http.MethodPost, "same-site", "http://other.home.local", http.StatusForbidden},
+		{"matching Origin only", http.MethodPost, "", "http://home.local", http.StatusNoContent},
+		{"foreign Origin only", http.MethodPost, "", "http://evil.local", http.StatusForbidden},
+		{"invalid Origin", http.MethodPost, "", "%%", http.StatusForbidden},
+		{"no headers, as curl", http.MethodPost, "", "", http.StatusNoContent},
+		{"cross-site GET", http.MethodGet, "cross-site", "http://evil.local", http.StatusNoContent},
+		{"cross-site PATCH", http.MethodPatch, "cross-site", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://home.local/api/links", nil)
+		if tt.fetchSite != "" {
+			req.Header.Set("Sec-Fetch-Site", tt.fetchSite)
+		}
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestLogSample(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", servePing)
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+
+	tests := []struct {
+		sample float64
+		path   string
+		want   int
+	}{
+		{0, "/ok", 0},
+		{0, "/missing", 20},
+		{0, "/broken", 20},
+		{1, "/ok", 20},
+		{1, "/broken", 20},
+	}
+	for _, tt := range tests {
+		buf := captureLog(t, slog.LevelInfo)
+		handler := logRequests(mux, false, tt.sample)
+		for range 20 {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+		}
+		if lines := strings.Count(buf.String(), "uri="+tt.path); lines != tt.want {
+			t.Errorf("-log-sample %g: %d of 20 requests to %s logged, want %d", tt.sample, lines, tt.path, tt.want)
+		}
+	}
+}
+
+func TestLogSampleFraction(t *testing.T) {
+	buf := captureLog(t, slog.LevelInfo)
+	handler := logRequests(http.HandlerFunc(servePing), false, 0.5)
+	for range 400 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+	}
+	// Far outside what a fair coin gives over 400 requests
+	if lines := strings.Count(buf.String(), "uri=/ping"); lines < 100 || lines > 300 {
+		t.Errorf("%d of 400 requests logged with -log-sample 0.5", lines)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !embed_config
+
+package main
+
+// embeddedConfig is nil without the embed_config build tag, a missing
+// -config file is then an error
+var embeddedConfig []byte
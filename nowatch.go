@@ -0,0 +1,10 @@
+//go:build no_watch
+
+package main
+
+// watchSupported is false in builds with the no_watch tag: the
+// configuration is loaded once at startup and never reloaded
+const watchSupported = false
+
+// watchConfig is never called when watchSupported is false
+func watchConfig(configPath string, handler *Handler) {}
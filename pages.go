@@ -0,0 +1,143 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Page is an extra page of links served at /<name>. It has its own title,
+// links and groups, the other settings are the ones of the configuration.
+type Page struct {
+	Title string `yaml:"title,omitempty"`
+	// Icon is shown before the title in the navigation bar, typically an
+	// emoji
+	Icon string `yaml:"icon,omitempty"`
+	// Order sorts the pages in the navigation bar, lowest first, pages
+	// with the same order are sorted by name
+	Order  int     `yaml:"order,omitempty"`
+	Links  []Link  `yaml:"links,omitempty"`
+	Groups []Group `yaml:"groups,omitempty"`
+}
+
+// navItem is an entry of the navigation bar
+type navItem struct {
+	Path    string
+	Title   string
+	Icon    string
+	Current bool
+}
+
+// pageNames returns the names of the pages of config in display order
+func pageNames(config Configuration) []string {
+	names := slices.Sorted(maps.Keys(config.Pages))
+	slices.SortStableFunc(names, func(a, b string) int {
+		return cmp.Compare(config.Pages[a].Order, config.Pages[b].Order)
+	})
+	return names
+}
+
+// navigation returns the navigation bar of config with the page called
+// current highlighted, the main page first. It is empty when there are no
+// other pages.
+func navigation(config Configuration, current string) []navItem {
+	if len(config.Pages) == 0 {
+		return nil
+	}
+	nav := []navItem{{Path: "/", Title: cmp.Or(config.Title, "Links"), Current: current == ""}}
+	for _, name := range pageNames(config) {
+		page := config.Pages[name]
+		nav = append(nav, navItem{
+			Path:    "/" + url.PathEscape(name),
+			Title:   cmp.Or(page.Title, name),
+			Icon:    page.Icon,
+			Current: current == name,
+		})
+	}
+	return nav
+}
+
+// view returns what is rendered at /<name>: the links of the page, or the
+// top-level ones for an empty name, with the settings of config. A view
+// has no pages of its own. It reports false when there is no such page.
+func (c Configuration) view(name string) (Configuration, bool) {
+	if name != "" {
+		page, ok := c.Pages[name]
+		if !ok {
+			return Configuration{}, false
+		}
+		c.Title = page.Title
+		c.Links = page.Links
+		c.Groups = page.Groups
+	}
+	c.Pages = nil
+	c.Groups = orderGroups(c.Groups, c.GroupOrder)
+	return c, true
+}
+
+// views returns the main page followed by every other page, see view
+func (c Configuration) views() []Configuration {
+	views := make([]Configuration, 0, len(c.Pages)+1)
+	for _, name := range append([]string{""}, pageNames(c)...) {
+		view, _ := c.view(name)
+		views = append(views, view)
+	}
+	return views
+}
+
+// finishPages validates the page names and applies the auto group rules
+// to the top-level links of every page
+func finishPages(config *Configuration) error {
+	for name, page := range config.Pages {
+		if name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("invalid page name %q: it must be non-empty and can't contain '/'", name)
+		}
+		if isReservedName(name) {
+			return fmt.Errorf("invalid page name %q: /%s is a route of the server", name, name)
+		}
+		grouped := Configuration{Links: page.Links, Groups: page.Groups, AutoGroups: config.AutoGroups}
+		if err := applyAutoGroups(&grouped); err != nil {
+			return err
+		}
+		page.Links, page.Groups = grouped.Links, grouped.Groups
+		config.Pages[name] = page
+	}
+	return nil
+}
+
+// mergePages adds the pages of src to dst, pages sharing a name are
+// combined like the main page
+func mergePages(dst, src map[string]Page) map[string]Page {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]Page)
+	}
+	for name, page := range src {
+		existing, ok := dst[name]
+		if !ok {
+			dst[name] = page
+			continue
+		}
+		merged := mergeConfigs(
+			Configuration{Links: existing.Links, Groups: existing.Groups},
+			Configuration{Links: page.Links, Groups: page.Groups},
+		)
+		existing.Links, existing.Groups = merged.Links, merged.Groups
+		if page.Title != "" {
+			existing.Title = page.Title
+		}
+		if page.Icon != "" {
+			existing.Icon = page.Icon
+		}
+		if page.Order != 0 {
+			existing.Order = page.Order
+		}
+		dst[name] = existing
+	}
+	return dst
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const pagesConfig = `
+title: Home
+links:
+  - {name: Router, url: "http://router.local"}
+pages:
+  work:
+    title: Work
+    links:
+      - {name: Jira, url: "http://jira.local"}
+  media:
+    title: Media
+    groups:
+      - name: Streaming
+        links:
+          - {name: Jellyfin, url: "http://jellyfin.local"}
+`
+
+func TestPageRouting(t *testing.T) {
+	result, err := loadString(t, pagesConfig, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := newTestMux(t, newTestHandler(t, result.Config), AppConfig{})
+
+	tests := []struct {
+		path    string
+		want    int
+		title   string
+		link    string
+		notLink string
+	}{
+		{path: "/", want: http.StatusOK, title: "Home", link: "Router", notLink: "Jira"},
+		{path: "/work", want: http.StatusOK, title: "Work", link: "Jira", notLink: "Router"},
+		{path: "/media", want: http.StatusOK, title: "Media", link: "Jellyfin", notLink: "Jira"},
+		{path: "/unknown", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := get(t, mux, tt.path)
+			if rec.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d", tt.path, rec.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, "<title>"+tt.title+"</title>") {
+				t.Errorf("GET %s has no %s title", tt.path, tt.title)
+			}
+			if !strings.Contains(body, ">"+tt.link+"</a>") {
+				t.Errorf("GET %s does not link to %s", tt.path, tt.link)
+			}
+			if strings.Contains(body, ">"+tt.notLink+"</a>") {
+				t.Errorf("GET %s links to %s of another page", tt.path, tt.notLink)
+			}
+		})
+	}
+}
+
+func TestInvalidPageName(t *testing.T) {
+	if _, err := loadString(t, "pages:\n  a/b:\n    title: Nested\n", loadOptions{}); err == nil {
+		t.Error("a page name with a slash was accepted")
+	}
+	for _, name := range []string{"metrics", "admin", "api", "version.json"} {
+		_, err := loadString(t, "pages:\n  "+name+":\n    title: Taken\n", loadOptions{})
+		if err == nil || !strings.Contains(err.Error(), "is a route of the server") {
+			t.Errorf("page %q: load error = %v, want the route collision", name, err)
+		}
+	}
+}
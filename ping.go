@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// pingResponse is the answer of /ping
+type pingResponse struct {
+	Time      string `json:"time"`
+	Timezone  string `json:"timezone"`
+	UTCOffset string `json:"utc_offset"`
+}
+
+// servePing answers with the server clock and timezone, for checking
+// connectivity and clock skew by hand
+func servePing(w http.ResponseWriter, req *http.Request) {
+	now := time.Now()
+	zone, _ := now.Zone()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(pingResponse{
+		Time:      now.Format(time.RFC3339),
+		Timezone:  zone,
+		UTCOffset: now.Format("-07:00"),
+	})
+}
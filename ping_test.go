@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPing(t *testing.T) {
+	mux := newTestMux(t, newTestHandler(t, Configuration{}), AppConfig{})
+	before := time.Now().Add(-time.Second)
+	rec := get(t, mux, "/ping")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("GET /ping = %d %v", rec.Code, rec.Header())
+	}
+	var ping pingResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &ping); err != nil {
+		t.Fatal(err)
+	}
+	at, err := time.Parse(time.RFC3339, ping.Time)
+	if err != nil {
+		t.Fatalf("time %q is not RFC3339: %v", ping.Time, err)
+	}
+	if at.Before(before) || at.After(time.Now()) {
+		t.Errorf("time = %s, want now", at)
+	}
+	if ping.Timezone == "" || ping.UTCOffset != at.Format("-07:00") {
+		t.Errorf("timezone = %q, offset = %q", ping.Timezone, ping.UTCOffset)
+	}
+}
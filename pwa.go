@@ -0,0 +1,73 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// AppIcon is an icon of the installed app, listed in the web manifest
+type AppIcon struct {
+	Src   string `yaml:"src" json:"src"`
+	Sizes string `yaml:"sizes,omitempty" json:"sizes,omitempty"`
+	Type  string `yaml:"type,omitempty" json:"type,omitempty"`
+}
+
+// appIconPath serves defaultAppIcon, the app icon when the configuration
+// lists none
+const appIconPath = "/icon.svg"
+
+const defaultAppIcon = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64"><rect width="64" height="64" rx="12" fill="#0066cc"/><path d="M18 22h28M18 32h28M18 42h18" stroke="#fff" stroke-width="5" stroke-linecap="round"/></svg>`
+
+// serviceWorker is the minimal service worker browsers want before
+// offering to install the page. It doesn't cache anything, the page always
+// comes from the server.
+const serviceWorker = `self.addEventListener("fetch", function (event) {
+    event.respondWith(fetch(event.request));
+});
+`
+
+// webManifest is the web app manifest of the page
+type webManifest struct {
+	Name            string    `json:"name"`
+	ShortName       string    `json:"short_name"`
+	StartURL        string    `json:"start_url"`
+	Display         string    `json:"display"`
+	BackgroundColor string    `json:"background_color"`
+	ThemeColor      string    `json:"theme_color"`
+	Icons           []AppIcon `json:"icons"`
+}
+
+// manifest returns the web manifest of config
+func manifest(config Configuration) webManifest {
+	name := cmp.Or(config.Title, "Links")
+	icons := config.AppIcons
+	if len(icons) == 0 {
+		icons = []AppIcon{{Src: appIconPath, Sizes: "any", Type: "image/svg+xml"}}
+	}
+	return webManifest{
+		Name:            name,
+		ShortName:       name,
+		StartURL:        "/",
+		Display:         "standalone",
+		BackgroundColor: "#ffffff",
+		ThemeColor:      "#0066cc",
+		Icons:           icons,
+	}
+}
+
+func (h *Handler) serveManifest(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/manifest+json")
+	json.NewEncoder(w).Encode(manifest(h.getConfig()))
+}
+
+func serveServiceWorker(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript")
+	io.WriteString(w, serviceWorker)
+}
+
+func serveAppIcon(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	io.WriteString(w, defaultAppIcon)
+}
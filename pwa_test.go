@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Configuration
+		want   string
+		icon   string
+	}{
+		{"default icon", Configuration{Title: "Home lab"}, "Home lab", appIconPath},
+		{"no title", Configuration{}, "Links", appIconPath},
+		{"configured icons", Configuration{Title: "Home lab", AppIcons: []AppIcon{{Src: "/static/icon-192.png", Sizes: "192x192", Type: "image/png"}}}, "Home lab", "/static/icon-192.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newTestMux(t, newTestHandler(t, tt.config), AppConfig{PWA: true})
+			rec := get(t, mux, "/manifest.webmanifest")
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/manifest+json" {
+				t.Fatalf("GET /manifest.webmanifest = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+			}
+			var m webManifest
+			if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+				t.Fatal(err)
+			}
+			if m.Name != tt.want || m.StartURL != "/" || m.Display != "standalone" {
+				t.Errorf("manifest = %+v, want %q installable at /", m, tt.want)
+			}
+			if len(m.Icons) == 0 || m.Icons[0].Src != tt.icon {
+				t.Errorf("icons = %+v, want %s first", m.Icons, tt.icon)
+			}
+		})
+	}
+}
+
+func TestPWARoutes(t *testing.T) {
+	h := newTestHandler(t, Configuration{})
+	if rec := get(t, newTestMux(t, h, AppConfig{}), "/manifest.webmanifest"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /manifest.webmanifest without -pwa = %d, want 404", rec.Code)
+	}
+	mux := newTestMux(t, h, AppConfig{PWA: true})
+	for path, contentType := range map[string]string{"/sw.js": "text/javascript", appIconPath: "image/svg+xml"} {
+		if rec := get(t, mux, path); rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != contentType {
+			t.Errorf("GET %s = %d %q, want 200 %s", path, rec.Code, rec.Header().Get("Content-Type"), contentType)
+		}
+	}
+
+	h.pwa = true
+	body := get(t, http.HandlerFunc(h.index), "/").Body.String()
+	for _, want := range []string{`<link rel="manifest" href="/manifest.webmanifest">`, `navigator.serviceWorker.register("/sw.js")`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("the page does not contain %s", want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// errConfigFileName is returned when the file to reload doesn't name
+// exactly one of the configuration files
+var errConfigFileName = errors.New("invalid configuration file name")
+
+// findConfigFile returns the index of the file of files called name, its
+// source or, when no other file shares it, its base name
+func findConfigFile(files []configFile, name string) (int, error) {
+	if i := slices.IndexFunc(files, func(file configFile) bool { return file.source == name }); i >= 0 {
+		return i, nil
+	}
+	found := -1
+	for i, file := range files {
+		if filepath.Base(file.source) != name {
+			continue
+		}
+		if found >= 0 {
+			return -1, fmt.Errorf("%w: %q matches several files, use its full path", errConfigFileName, name)
+		}
+		found = i
+	}
+	if found < 0 {
+		return -1, fmt.Errorf("%w: %q is not one of the files", errConfigFileName, name)
+	}
+	return found, nil
+}
+
+// reloadFile reads the configuration file called name again, see
+// findConfigFile, and applies it merged with the other files as they were
+// last read. The templates are kept. It returns the configuration
+// replaced.
+func (h *Handler) reloadFile(ctx context.Context, name string) (result LoadResult, previous Configuration, err error) {
+	start := time.Now()
+	reloadSpan := h.tracer.start(ctx, "config.reload", spanKindInternal)
+	defer func() {
+		h.metrics.observeReload(time.Since(start), err)
+		h.tracer.end(reloadSpan, err)
+	}()
+
+	h.mu.RLock()
+	files := slices.Clone(h.files)
+	h.mu.RUnlock()
+	i, err := findConfigFile(files, name)
+	if err != nil {
+		return LoadResult{}, Configuration{}, err
+	}
+	if files[i].source == stdinConfig {
+		return LoadResult{}, Configuration{}, errors.New("the configuration read from stdin can't be read again")
+	}
+	if files[i].data, err = readConfigSource(ctx, files[i].source, h.load.client); err != nil {
+		return LoadResult{}, Configuration{}, err
+	}
+	if result, err = assembleConfig(files, h.load); err != nil {
+		return LoadResult{}, Configuration{}, err
+	}
+	previous = h.updateConfig(result)
+	h.reloaded(result)
+	return result, previous, nil
+}
+
+// apiReload answers /api/reload: it reloads the configuration file named by
+// the file parameter, or the whole configuration without it, and returns
+// the changes applied
+func (h *Handler) apiReload(w http.ResponseWriter, req *http.Request) {
+	// The diff is against the configuration the reload replaced, not one
+	// read earlier that another reload may have replaced meanwhile
+	var result LoadResult
+	var previous Configuration
+	var err error
+	if file := req.URL.Query().Get("file"); file != "" {
+		result, previous, err = h.reloadFile(req.Context(), file)
+	} else if h.configFile == stdinConfig {
+		err = errors.New("the configuration read from stdin can't be read again")
+	} else {
+		result, previous, err = h.reload(req.Context(), h.configFile)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, errConfigFileName) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(diffConfig(previous, result.Config))
+}
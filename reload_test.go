@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newReloadMux serves a handler loaded from a home and a work file in a
+// temporary directory, returning the mux and the directory
+func newReloadMux(t *testing.T) (*Handler, http.Handler, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, "10-home.yaml", "links:\n  - {name: Router, url: http://router.local}\n")
+	writeFile(t, dir, "20-work.yaml", "links:\n  - {name: Jira, url: http://jira.local}\n")
+	pattern := filepath.Join(dir, "*.yaml")
+	result, err := loadConfig(pattern, loadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandler(t, result.Config)
+	h.configFile = pattern
+	h.updateConfig(result)
+	return h, newTestMux(t, h, AppConfig{ReloadToken: "token"}), dir
+}
+
+// postReload posts to target with the reload token when token is set
+func postReload(t *testing.T, mux http.Handler, target string, token bool) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, nil)
+	if token {
+		req.Header.Set("Authorization", "Bearer token")
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestReloadFile(t *testing.T) {
+	h, mux, dir := newReloadMux(t)
+	writeFile(t, dir, "10-home.yaml", "links:\n  - {name: NAS, url: http://nas.local}\n")
+	writeFile(t, dir, "20-work.yaml", "links:\n  - {name: Jira, url: http://jira.example.com}\n  - {name: Wiki, url: http://wiki.local}\n")
+
+	rec := postReload(t, mux, "/api/reload?file=20-work.yaml", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d %s, want 200", rec.Code, rec.Body)
+	}
+	var diff Diff
+	if err := json.Unmarshal(rec.Body.Bytes(), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if got := linkNames(diff.Added); !reflect.DeepEqual(got, []string{"Wiki"}) {
+		t.Errorf("added = %v, want Wiki", got)
+	}
+	if len(diff.Removed) != 0 {
+		t.Errorf("removed = %v, want none: the home file is not read again", linkNames(diff.Removed))
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Name != "Jira" || diff.Changed[0].New.Url != "http://jira.example.com" {
+		t.Errorf("changed = %+v, want the Jira URL", diff.Changed)
+	}
+	if got := linkNames(h.config.Links); !reflect.DeepEqual(got, []string{"Router", "Jira", "Wiki"}) {
+		t.Errorf("links = %v, want the cached home file merged with the new work file", got)
+	}
+
+	// Without a file, the whole configuration is read again
+	rec = postReload(t, mux, "/api/reload", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("full reload status = %d %s, want 200", rec.Code, rec.Body)
+	}
+	if got := linkNames(h.config.Links); !reflect.DeepEqual(got, []string{"NAS", "Jira", "Wiki"}) {
+		t.Errorf("links after a full reload = %v, want NAS, Jira, Wiki", got)
+	}
+}
+
+func TestReloadFileErrors(t *testing.T) {
+	_, mux, dir := newReloadMux(t)
+	tests := []struct {
+		name   string
+		target string
+		token  bool
+		want   int
+	}{
+		{"no token", "/api/reload?file=20-work.yaml", false, http.StatusUnauthorized},
+		{"unknown file", "/api/reload?file=other.yaml", true, http.StatusBadRequest},
+		{"file outside the configuration", "/api/reload?file=" + filepath.Join(dir, "..", "20-work.yaml"), true, http.StatusBadRequest},
+		{"by full path", "/api/reload?file=" + filepath.Join(dir, "20-work.yaml"), true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := postReload(t, mux, tt.target, tt.token); rec.Code != tt.want {
+				t.Errorf("status = %d %s, want %d", rec.Code, rec.Body, tt.want)
+			}
+		})
+	}
+
+	writeFile(t, dir, "20-work.yaml", "links: [")
+	if rec := postReload(t, mux, "/api/reload?file=20-work.yaml", true); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reload of an invalid file = %d %s, want 422", rec.Code, rec.Body)
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	files := []configFile{{source: "home/links.yaml"}, {source: "work/links.yaml"}, {source: "work/extra.yaml"}}
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"work/links.yaml", 1},
+		{"extra.yaml", 2},
+		{"links.yaml", -1},
+		{"missing.yaml", -1},
+	}
+	for _, tt := range tests {
+		got, err := findConfigFile(files, tt.name)
+		if got != tt.want || (err != nil) != (tt.want < 0) {
+			t.Errorf("findConfigFile(%q) = %d, %v, want %d", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestUpdateReturnsPrevious(t *testing.T) {
+	h := newTestHandler(t, Configuration{Hash: "1", Links: []Link{{Name: "A", Url: "http://a.local"}}})
+	second := Configuration{Hash: "2", Links: []Link{{Name: "B", Url: "http://b.local"}}}
+	if previous := h.updateConfig(LoadResult{Config: second}); previous.Hash != "1" {
+		t.Errorf("first update replaced %q, want 1", previous.Hash)
+	}
+	if previous := h.updateConfig(LoadResult{Config: Configuration{Hash: "3"}}); !reflect.DeepEqual(previous, second) {
+		t.Errorf("second update replaced %+v, want %+v", previous, second)
+	}
+}
+
+func TestReloadDiffsAgainstReplacedConfig(t *testing.T) {
+	h, mux, dir := newReloadMux(t)
+	// Another reload replaced the configuration the handler was loaded with
+	h.updateConfig(LoadResult{Config: Configuration{Hash: "other", Links: []Link{{Name: "NAS", Url: "http://nas.local"}}}, files: h.files})
+	writeFile(t, dir, "10-home.yaml", "links:\n  - {name: NAS, url: http://nas.local}\n")
+
+	rec := postReload(t, mux, "/api/reload", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d %s, want 200", rec.Code, rec.Body)
+	}
+	var diff Diff
+	if err := json.Unmarshal(rec.Body.Bytes(), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if diff.OldHash != "other" || len(diff.Removed) != 0 || !reflect.DeepEqual(linkNames(diff.Added), []string{"Jira"}) {
+		t.Errorf("diff = %+v, want Jira added to the configuration in place", diff)
+	}
+}
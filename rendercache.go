@@ -0,0 +1,58 @@
+package main
+
+import (
+	"html/template"
+	"sync"
+	"time"
+)
+
+// renderedPage is a page as last rendered, with what it was rendered from
+type renderedPage struct {
+	body     []byte
+	hash     string
+	template *template.Template
+	rendered time.Time
+}
+
+// renderCache keeps the rendered pages in memory so they can be served
+// without executing the template. A page is rendered again once it is
+// older than ttl, which bounds how stale link statuses and descriptions
+// get, or as soon as the configuration, the templates or the imported
+// links change.
+type renderCache struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	pages map[string]renderedPage // keyed by page name or header rule
+}
+
+func newRenderCache(ttl time.Duration) *renderCache {
+	return &renderCache{ttl: ttl, pages: make(map[string]renderedPage)}
+}
+
+// get returns the page called name if it was rendered from the
+// configuration hash and tmpl less than ttl ago
+func (c *renderCache) get(name, hash string, tmpl *template.Template) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	page, ok := c.pages[name]
+	if !ok || page.hash != hash || page.template != tmpl || time.Since(page.rendered) >= c.ttl {
+		return nil, false
+	}
+	return page.body, true
+}
+
+// put stores the page called name as rendered from hash and tmpl
+func (c *renderCache) put(name, hash string, tmpl *template.Template, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages[name] = renderedPage{body: body, hash: hash, template: tmpl, rendered: time.Now()}
+}
+
+// invalidate drops every page, for changes the configuration hash doesn't
+// show such as newly imported links
+func (c *renderCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.pages)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// benchmarkConfig has 10 groups of 20 links
+func benchmarkConfig() Configuration {
+	var config Configuration
+	for g := range 10 {
+		group := Group{Name: fmt.Sprintf("Group %d", g)}
+		for l := range 20 {
+			group.Links = append(group.Links, Link{
+				Name:        fmt.Sprintf("Link %d.%d", g, l),
+				Url:         fmt.Sprintf("http://host%d-%d.local/", g, l),
+				Description: "A service of the home lab",
+			})
+		}
+		config.Groups = append(config.Groups, group)
+	}
+	config.Hash = "bench"
+	return config
+}
+
+func TestRenderCache(t *testing.T) {
+	h := newTestHandler(t, Configuration{Hash: "1", Links: []Link{{Name: "A", Url: "http://a.local"}}})
+	h.rendered = newRenderCache(time.Hour)
+	index := http.HandlerFunc(h.index)
+
+	first := get(t, index, "/").Body.String()
+	if second := get(t, index, "/").Body.String(); second != first {
+		t.Error("the cached page differs from the rendered one")
+	}
+	body := get(t, http.HandlerFunc(h.serveMetrics), "/metrics").Body.String()
+	if hits, misses := metricValue(t, body, `render_cache_requests_total{result="hit"}`), metricValue(t, body, `render_cache_requests_total{result="miss"}`); hits != "1" || misses != "1" {
+		t.Errorf("render cache hits, misses = %s, %s, want 1, 1", hits, misses)
+	}
+
+	// A configuration change renders the page again
+	h.updateConfig(LoadResult{Config: Configuration{Hash: "2", Links: []Link{{Name: "B", Url: "http://b.local"}}}})
+	if body := get(t, index, "/").Body.String(); body == first {
+		t.Error("the page was served from the cache after a configuration change")
+	}
+}
+
+func TestRenderCacheTTL(t *testing.T) {
+	c := newRenderCache(time.Minute)
+	c.put("", "hash", nil, []byte("page"))
+	if body, ok := c.get("", "hash", nil); !ok || string(body) != "page" {
+		t.Errorf("get = %q %v, want the page", body, ok)
+	}
+	if _, ok := c.get("", "other", nil); ok {
+		t.Error("get with another hash hit the cache")
+	}
+	c.pages[""] = renderedPage{body: []byte("page"), hash: "hash", rendered: time.Now().Add(-2 * time.Minute)}
+	if _, ok := c.get("", "hash", nil); ok {
+		t.Error("get of an expired page hit the cache")
+	}
+}
+
+func benchmarkIndex(b *testing.B, cache *renderCache) {
+	h, err := NewHandler(benchmarkConfig(), "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	h.rendered = cache
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	b.ReportAllocs()
+	for b.Loop() {
+		h.index(httptest.NewRecorder(), req)
+	}
+}
+
+func BenchmarkIndexRendered(b *testing.B) { benchmarkIndex(b, nil) }
+
+func BenchmarkIndexCached(b *testing.B) { benchmarkIndex(b, newRenderCache(time.Hour)) }
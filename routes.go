@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// route is one entry of the route registry
+type route struct {
+	Pattern string
+	Methods []string
+	// Name describes the handler in -print-routes output
+	Name    string
+	Handler http.HandlerFunc
+}
+
+// newRoutes returns every route the server exposes. All routes go through
+// this registry so the mux and -print-routes never disagree.
+func newRoutes(handler *Handler, appConfig AppConfig) ([]route, error) {
+	robots := []byte(defaultRobots)
+	if appConfig.RobotsFile != "" {
+		var err error
+		if robots, err = os.ReadFile(appConfig.RobotsFile); err != nil {
+			return nil, fmt.Errorf("failed to read robots.txt: %w", err)
+		}
+	}
+
+	schema, err := json.MarshalIndent(configSchema(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to build config schema: %w", err)
+	}
+
+	routes := []route{
+		{"/", []string{http.MethodGet}, "index and aliases", handler.index},
+		{"/export/chrome.json", []string{http.MethodGet}, "Chrome bookmarks export", handler.exportChrome},
+		{"/robots.txt", []string{http.MethodGet}, "robots.txt", serveRobots(robots)},
+		{"/healthz", []string{http.MethodGet}, "liveness probe", handler.healthz},
+		{"/favicons/", []string{http.MethodGet}, "link favicons", handler.favicon},
+		{"/version", []string{http.MethodGet}, "build information", serveVersion},
+		{"/version.json", []string{http.MethodGet}, "build information", serveVersion},
+		{"/ping", []string{http.MethodGet}, "server time and timezone", servePing},
+		{"/metrics", []string{http.MethodGet}, "Prometheus metrics", handler.serveMetrics},
+		{"/api/schema", []string{http.MethodGet}, "config JSON Schema", serveSchema(schema)},
+		{"/api/warnings", []string{http.MethodGet}, "config load warnings", handler.apiWarnings},
+	}
+
+	if appConfig.Whoami {
+		routes = append(routes, route{"/whoami", []string{http.MethodGet}, "request as seen by the server", serveWhoami(appConfig.TrustedProxies)})
+	}
+
+	if appConfig.PWA {
+		routes = append(routes,
+			route{"/manifest.webmanifest", []string{http.MethodGet}, "web app manifest", handler.serveManifest},
+			route{"/sw.js", []string{http.MethodGet}, "service worker", serveServiceWorker},
+			route{appIconPath, []string{http.MethodGet}, "default app icon", serveAppIcon},
+		)
+	}
+
+	if handler.editor != nil {
+		auth := func(h http.HandlerFunc) http.HandlerFunc {
+			return sameOrigin(requireAuth(h, appConfig.AuthUser, appConfig.AuthPass))
+		}
+		routes = append(routes,
+			route{"/admin", []string{http.MethodGet}, "admin page (auth)", auth(handler.admin)},
+			route{"/api/links", []string{http.MethodGet, http.MethodPost}, "list and add links (auth)", auth(handler.apiLinks)},
+			route{"/api/links/update", []string{http.MethodPost}, "update a link (auth)", auth(handler.apiUpdateLink)},
+			route{"/api/links/delete", []string{http.MethodPost}, "delete a link (auth)", auth(handler.apiDeleteLink)},
+			route{"/api/links/order", []string{http.MethodPatch}, "reorder the links of a group (auth)", auth(handler.apiReorderLinks)},
+		)
+	}
+
+	if appConfig.ReloadToken != "" {
+		token := func(h http.HandlerFunc) http.HandlerFunc {
+			return requireToken(h, appConfig.ReloadToken)
+		}
+		routes = append(routes,
+			route{"/-/diff", []string{http.MethodGet}, "changes of the last reload (token)", token(handler.diff)},
+			route{"/api/reload", []string{http.MethodPost}, "reload the config, or one file of it (token)", token(handler.apiReload)},
+			route{"/-/favicons/refresh", []string{http.MethodPost}, "refetch every favicon (token)", token(handler.refreshFavicons)},
+		)
+	}
+
+	if appConfig.ReadOnly {
+		for i := range routes {
+			routes[i].Handler = rejectWrites(routes[i].Handler)
+		}
+	}
+	return routes, nil
+}
+
+var (
+	reservedOnce sync.Once
+	reserved     map[string]bool
+)
+
+// isReservedName reports whether /<name> is served by a route other than
+// the index. The names are the first path segments of the routes newRoutes
+// can register, with every optional route enabled. Pages and aliases are
+// served by the / route, which the others take precedence over, so they
+// can't use these names.
+func isReservedName(name string) bool {
+	reservedOnce.Do(func() {
+		routes, err := newRoutes(&Handler{editor: &configEditor{}}, AppConfig{Whoami: true, PWA: true, ReloadToken: "reserved"})
+		if err != nil {
+			panic(err)
+		}
+		reserved = make(map[string]bool)
+		for _, r := range routes {
+			if name, _, _ := strings.Cut(strings.TrimPrefix(r.Pattern, "/"), "/"); name != "" {
+				reserved[name] = true
+			}
+		}
+	})
+	return reserved[name]
+}
+
+// newMux registers routes on a new ServeMux
+func newMux(routes []route) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, r := range routes {
+		mux.HandleFunc(r.Pattern, allowMethods(r.Handler, r.Methods...))
+	}
+	return mux
+}
+
+// printRoutes writes a table of routes to w
+func printRoutes(w io.Writer, routes []route) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATTERN\tMETHODS\tHANDLER")
+	for _, r := range routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Pattern, strings.Join(r.Methods, ","), r.Name)
+	}
+	tw.Flush()
+}
+
+// defaultRobots keeps every crawler away from the page
+const defaultRobots = "User-agent: *\nDisallow: /\n"
+
+// serveRobots answers /robots.txt with content
+func serveRobots(content []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(content)
+	}
+}
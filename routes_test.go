@@ -0,0 +1,218 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestMux returns the mux main would serve handler with for appConfig
+func newTestMux(t *testing.T, handler *Handler, appConfig AppConfig) *http.ServeMux {
+	t.Helper()
+	routes, err := newRoutes(handler, appConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newMux(routes)
+}
+
+func TestRobots(t *testing.T) {
+	custom := writeFile(t, t.TempDir(), "robots.txt", "User-agent: *\nDisallow: /admin\n")
+	tests := []struct {
+		name, file, want string
+	}{
+		{"default", "", "User-agent: *\nDisallow: /\n"},
+		{"custom file", custom, "User-agent: *\nDisallow: /admin\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newTestMux(t, newTestHandler(t, Configuration{}), AppConfig{RobotsFile: tt.file})
+			rec := get(t, mux, "/robots.txt")
+			if rec.Code != http.StatusOK || rec.Body.String() != tt.want {
+				t.Errorf("GET /robots.txt = %d %q, want 200 %q", rec.Code, rec.Body, tt.want)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+				t.Errorf("Content-Type = %q, want text/plain", ct)
+			}
+		})
+	}
+}
+
+func TestRobotsMissingFile(t *testing.T) {
+	_, err := newRoutes(newTestHandler(t, Configuration{}), AppConfig{RobotsFile: filepath.Join(t.TempDir(), "missing.txt")})
+	if err == nil {
+		t.Error("newRoutes accepted a -robots file that doesn't exist")
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	mux := newTestMux(t, newTestHandler(t, Configuration{}), AppConfig{ReloadToken: "secret"})
+	tests := []struct {
+		method, target, allow string
+	}{
+		{http.MethodPost, "/", "GET, HEAD"},
+		{http.MethodPut, "/", "GET, HEAD"},
+		{http.MethodDelete, "/healthz", "GET, HEAD"},
+		{http.MethodPost, "/api/warnings", "GET, HEAD"},
+		{http.MethodPost, "/robots.txt", "GET, HEAD"},
+		{http.MethodGet, "/api/reload", "POST"},
+		{http.MethodPut, "/-/favicons/refresh", "POST"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s = %d, want 405", tt.method, tt.target, rec.Code)
+		}
+		if allow := rec.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.target, allow, tt.allow)
+		}
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s / = %d, want 200", method, rec.Code)
+		}
+	}
+}
+
+func TestNewMuxIsolated(t *testing.T) {
+	home := newTestMux(t, newTestHandler(t, Configuration{Title: "Home"}), AppConfig{})
+	work := newTestMux(t, newTestHandler(t, Configuration{Title: "Work"}), AppConfig{})
+	if body := get(t, home, "/").Body.String(); !strings.Contains(body, "<h1>Home</h1>") {
+		t.Error("the first mux does not serve its own configuration")
+	}
+	if body := get(t, work, "/").Body.String(); !strings.Contains(body, "<h1>Work</h1>") {
+		t.Error("the second mux does not serve its own configuration")
+	}
+	if _, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/healthz", nil)); pattern != "" {
+		t.Errorf("DefaultServeMux has %q registered, want nothing", pattern)
+	}
+}
+
+func TestRoutesRegistry(t *testing.T) {
+	patterns := func(appConfig AppConfig) map[string]bool {
+		routes, err := newRoutes(newTestHandler(t, Configuration{}), appConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := make(map[string]bool, len(routes))
+		for _, r := range routes {
+			if r.Name == "" || len(r.Methods) == 0 || r.Handler == nil {
+				t.Errorf("route %s is incomplete: %+v", r.Pattern, r)
+			}
+			found[r.Pattern] = true
+		}
+		return found
+	}
+
+	core := patterns(AppConfig{})
+	for _, pattern := range []string{"/", "/healthz", "/metrics", "/version", "/robots.txt", "/api/schema", "/api/warnings"} {
+		if !core[pattern] {
+			t.Errorf("the registry has no %s route", pattern)
+		}
+	}
+	for _, pattern := range []string{"/whoami", "/api/reload", "/admin", "/manifest.webmanifest"} {
+		if core[pattern] {
+			t.Errorf("the registry has %s without the flag enabling it", pattern)
+		}
+	}
+
+	optional := patterns(AppConfig{Whoami: true, ReloadToken: "secret", PWA: true})
+	for _, pattern := range []string{"/whoami", "/api/reload", "/-/diff", "/manifest.webmanifest", "/sw.js"} {
+		if !optional[pattern] {
+			t.Errorf("the registry has no %s route with its flag set", pattern)
+		}
+	}
+}
+
+func TestPrintRoutes(t *testing.T) {
+	var buf strings.Builder
+	printRoutes(&buf, []route{
+		{"/", []string{http.MethodGet}, "index", nil},
+		{"/api/reload", []string{http.MethodPost}, "reload", nil},
+	})
+	want := "PATTERN      METHODS  HANDLER\n/            GET      index\n/api/reload  POST     reload\n"
+	if buf.String() != want {
+		t.Errorf("printRoutes wrote\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestMuxServesIndexAndHealthz(t *testing.T) {
+	mux := newTestMux(t, newTestHandler(t, Configuration{Links: []Link{{Name: "Grafana", Url: "http://grafana.local"}}}), AppConfig{})
+
+	rec := get(t, mux, "/")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `href="http://grafana.local"`) {
+		t.Errorf("GET / = %d, want 200 with the link", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/html" {
+		t.Errorf("GET /: Content-Type = %q, want text/html", ct)
+	}
+
+	rec = get(t, mux, "/healthz")
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok\n" {
+		t.Errorf("GET /healthz = %d %q, want 200 \"ok\\n\"", rec.Code, rec.Body)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	const content = "links:\n  - {name: Grafana, url: http://grafana.local}\n"
+	h, path := newEditingHandler(t, content)
+	appConfig := AppConfig{ReadOnly: true, AuthUser: "admin", AuthPass: "pass", ReloadToken: "token"}
+	mux := newTestMux(t, h, appConfig)
+
+	writes := []struct {
+		method, target, body string
+	}{
+		{http.MethodPost, "/api/links", `{"name": "New", "url": "http://new.local"}`},
+		{http.MethodPost, "/api/links/update", `{"index": 0, "name": "Renamed", "url": "http://grafana.local"}`},
+		{http.MethodPost, "/api/links/delete", `{"index": 0}`},
+		{http.MethodPatch, "/api/links/order", `{"order": [0]}`},
+		{http.MethodPost, "/api/reload", ""},
+		{http.MethodPost, "/-/favicons/refresh", ""},
+	}
+	for _, tt := range writes {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("admin", "pass")
+		if strings.HasPrefix(tt.target, "/api/reload") || strings.HasPrefix(tt.target, "/-/") {
+			req.Header.Set("Authorization", "Bearer token")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s in read-only mode = %d, want 403", tt.method, tt.target, rec.Code)
+		}
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != content {
+		t.Errorf("the config file changed in read-only mode: %q, %v", data, err)
+	}
+
+	for _, target := range []string{"/", "/api/warnings", "/admin", "/api/links"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetBasicAuth("admin", "pass")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s in read-only mode = %d, want 200", target, rec.Code)
+		}
+	}
+}
+
+func TestReservedNames(t *testing.T) {
+	for _, name := range []string{"healthz", "ping", "version", "version.json", "metrics", "robots.txt", "favicons", "export", "api", "admin", "whoami", "sw.js", "manifest.webmanifest", "icon.svg", "-"} {
+		if !isReservedName(name) {
+			t.Errorf("%q is not reserved", name)
+		}
+	}
+	for _, name := range []string{"", "work", "grafana"} {
+		if isReservedName(name) {
+			t.Errorf("%q is reserved", name)
+		}
+	}
+}
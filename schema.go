@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// configSchema returns the JSON Schema of the configuration file. It is
+// derived from the yaml tags of Configuration so it can't drift from what
+// the loader accepts: fields tagged omitempty are optional, the others
+// required.
+func configSchema() map[string]any {
+	schema := typeSchema(reflect.TypeFor[Configuration]())
+	// Defaults is a Link, but the fields identifying a link are never
+	// taken from it, so none of its fields is required
+	delete(schema["properties"].(map[string]any)["defaults"].(map[string]any), "required")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "home configuration"
+	return schema
+}
+
+// typeSchema returns the schema of values of type t
+func typeSchema(t reflect.Type) map[string]any {
+	if t == reflect.TypeFor[time.Duration]() {
+		// Durations are written like 15m or 1h30m
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return map[string]any{}
+}
+
+// structSchema returns the schema of a struct from its yaml tags
+func structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		properties[name] = typeSchema(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// serveSchema answers /api/schema with the configuration schema
+func serveSchema(schema []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.Write(schema)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fill sets every field reachable from v to a non-zero value, so that
+// marshaling it writes every key
+func fill(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i))
+			}
+		}
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		elem := reflect.New(v.Type().Elem()).Elem()
+		fill(elem)
+		v.SetMapIndex(reflect.ValueOf("x"), elem)
+	}
+}
+
+// compareSchema checks that the properties of schema are exactly the keys
+// of value, a decoded YAML document, recursing into nested objects
+func compareSchema(t *testing.T, path string, schema map[string]any, value any) {
+	t.Helper()
+	switch schema["type"] {
+	case "object":
+		doc, ok := value.(map[string]any)
+		if !ok {
+			t.Errorf("%s: schema is an object, YAML has %T", path, value)
+			return
+		}
+		if extra, ok := schema["additionalProperties"].(map[string]any); ok {
+			for key, v := range doc {
+				compareSchema(t, path+"."+key, extra, v)
+			}
+			return
+		}
+		properties := schema["properties"].(map[string]any)
+		for key := range doc {
+			if _, ok := properties[key]; !ok {
+				t.Errorf("%s: %q is in the YAML but not in the schema", path, key)
+			}
+		}
+		for key, property := range properties {
+			v, ok := doc[key]
+			if !ok {
+				t.Errorf("%s: %q is in the schema but not in the YAML", path, key)
+				continue
+			}
+			compareSchema(t, path+"."+key, property.(map[string]any), v)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok || len(items) != 1 {
+			t.Errorf("%s: schema is an array, YAML has %v", path, value)
+			return
+		}
+		compareSchema(t, path+"[]", schema["items"].(map[string]any), items[0])
+	}
+}
+
+func TestConfigSchemaMatchesStructs(t *testing.T) {
+	var config Configuration
+	fill(reflect.ValueOf(&config).Elem())
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	compareSchema(t, "config", configSchema(), doc)
+}
+
+func TestConfigSchemaRequired(t *testing.T) {
+	schema := configSchema()
+	link := schema["properties"].(map[string]any)["links"].(map[string]any)["items"].(map[string]any)
+	if required, _ := link["required"].([]string); !slices.Equal(required, []string{"name", "url"}) {
+		t.Errorf("links are required to have %v, want [name url]", link["required"])
+	}
+	if _, ok := schema["required"]; ok {
+		t.Errorf("the configuration requires %v, want nothing", schema["required"])
+	}
+}
+
+// schemaErrors returns where value, a decoded YAML document, misses a
+// required property of schema or has one schema doesn't know
+func schemaErrors(path string, schema map[string]any, value any) []string {
+	var errs []string
+	switch schema["type"] {
+	case "object":
+		doc, _ := value.(map[string]any)
+		if extra, ok := schema["additionalProperties"].(map[string]any); ok {
+			for key, v := range doc {
+				errs = append(errs, schemaErrors(path+"."+key, extra, v)...)
+			}
+			return errs
+		}
+		required, _ := schema["required"].([]string)
+		for _, key := range required {
+			if _, ok := doc[key]; !ok {
+				errs = append(errs, path+": missing "+key)
+			}
+		}
+		properties := schema["properties"].(map[string]any)
+		for key, v := range doc {
+			property, ok := properties[key].(map[string]any)
+			if !ok {
+				errs = append(errs, path+": unknown "+key)
+				continue
+			}
+			errs = append(errs, schemaErrors(path+"."+key, property, v)...)
+		}
+	case "array":
+		items, _ := value.([]any)
+		for _, item := range items {
+			errs = append(errs, schemaErrors(path+"[]", schema["items"].(map[string]any), item)...)
+		}
+	}
+	return errs
+}
+
+func TestConfigSchemaDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{"description only", "defaults:\n  description: Self-hosted\nlinks:\n  - {name: NAS, url: http://nas.local}\n", nil},
+		{"tags only", "defaults:\n  tags: [home]\n", nil},
+		{"link without a URL", "links:\n  - {name: NAS}\n", []string{"config.links[]: missing url"}},
+	}
+	schema := configSchema()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc map[string]any
+			if err := yaml.Unmarshal([]byte(tt.yaml), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if errs := schemaErrors("config", schema, doc); !slices.Equal(errs, tt.want) {
+				t.Errorf("schema errors = %q, want %q", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeSchema(t *testing.T) {
+	mux := newTestMux(t, newTestHandler(t, Configuration{}), AppConfig{})
+	rec := get(t, mux, "/api/schema")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/schema+json" {
+		t.Fatalf("GET /api/schema = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema["$schema"] != "https://json-schema.org/draft/2020-12/schema" || schema["type"] != "object" {
+		t.Errorf("schema = %v, want a draft 2020-12 object schema", schema)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Link fields the search box can match, listed in search_fields
+const (
+	searchName        = "name"
+	searchDescription = "description"
+	searchURL         = "url"
+	searchTags        = "tags"
+)
+
+var searchFieldNames = []string{searchName, searchDescription, searchURL, searchTags}
+
+// defaultSearchFields are matched when the configuration sets none
+var defaultSearchFields = []string{searchName, searchDescription}
+
+// checkSearchFields rejects unknown search_fields entries
+func checkSearchFields(config Configuration) error {
+	for _, field := range config.SearchFields {
+		if !slices.Contains(searchFieldNames, field) {
+			return fmt.Errorf("invalid search field %q: must be one of %s", field, strings.Join(searchFieldNames, ", "))
+		}
+	}
+	return nil
+}
+
+// searchText returns what the search box matches link against: the fields
+// of config.SearchFields, lowercased and joined with spaces
+func searchText(config Configuration, link Link) string {
+	fields := config.SearchFields
+	if len(fields) == 0 {
+		fields = defaultSearchFields
+	}
+	var parts []string
+	for _, field := range fields {
+		switch field {
+		case searchName:
+			parts = append(parts, link.Name)
+		case searchDescription:
+			parts = append(parts, link.Description)
+		case searchURL:
+			parts = append(parts, link.Url)
+		case searchTags:
+			parts = append(parts, link.Tags...)
+		}
+	}
+	return strings.ToLower(strings.Join(parts, " "))
+}
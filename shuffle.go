@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math/rand/v2"
+)
+
+// newShuffleRand returns the generator used by -shuffle. A zero seed picks
+// a random one, the seed in use is returned so it can be logged and passed
+// back with -shuffle-seed to reproduce an order.
+func newShuffleRand(seed uint64) (*rand.Rand, uint64) {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return rand.New(rand.NewPCG(seed, seed)), seed
+}
+
+// shuffleLinks randomizes the order of the top-level links and of the links
+// within each group. Groups keep their order.
+func shuffleLinks(config *Configuration, rng *rand.Rand) {
+	shuffle := func(links []Link) {
+		rng.Shuffle(len(links), func(i, j int) {
+			links[i], links[j] = links[j], links[i]
+		})
+	}
+	shuffle(config.Links)
+	for _, group := range config.Groups {
+		shuffle(group.Links)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// numberedConfig returns a configuration with n top-level links and a
+// group of n links
+func numberedConfig(n int) Configuration {
+	var config Configuration
+	group := Group{Name: "Group"}
+	for i := range n {
+		config.Links = append(config.Links, Link{Name: fmt.Sprintf("link-%02d", i), Url: fmt.Sprintf("http://%d.local", i)})
+		group.Links = append(group.Links, Link{Name: fmt.Sprintf("grouped-%02d", i), Url: fmt.Sprintf("http://g%d.local", i)})
+	}
+	config.Groups = []Group{group, {Name: "Empty"}}
+	return config
+}
+
+func TestShuffleLinksFixedSeed(t *testing.T) {
+	shuffled := func(seed uint64) Configuration {
+		config := numberedConfig(20)
+		rng, got := newShuffleRand(seed)
+		if got != seed {
+			t.Fatalf("newShuffleRand(%d) used seed %d", seed, got)
+		}
+		shuffleLinks(&config, rng)
+		return config
+	}
+
+	first, second := shuffled(42), shuffled(42)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("seed 42 gave two orders:\n%v\n%v", linkNames(first.Links), linkNames(second.Links))
+	}
+	original := numberedConfig(20)
+	if reflect.DeepEqual(linkNames(first.Links), linkNames(original.Links)) {
+		t.Error("shuffling 20 links left them in order")
+	}
+	if reflect.DeepEqual(linkNames(shuffled(43).Links), linkNames(first.Links)) {
+		t.Error("seeds 42 and 43 gave the same order")
+	}
+
+	// Every link is still there once, and groups keep their order
+	for i, links := range [][]Link{first.Links, first.Groups[0].Links} {
+		want := [][]Link{original.Links, original.Groups[0].Links}[i]
+		if got := slices.Sorted(slices.Values(linkNames(links))); !reflect.DeepEqual(got, linkNames(want)) {
+			t.Errorf("shuffled links %v are not a permutation of %v", got, linkNames(want))
+		}
+	}
+	if got := groupNames(first.Groups); !reflect.DeepEqual(got, []string{"Group", "Empty"}) {
+		t.Errorf("groups = %v, want their order kept", got)
+	}
+}
+
+func TestNewShuffleRandPicksSeed(t *testing.T) {
+	if _, seed := newShuffleRand(0); seed == 0 {
+		t.Error("newShuffleRand(0) kept the zero seed")
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSourceInterval is how often a source is fetched when it sets
+	// no interval
+	defaultSourceInterval = 15 * time.Minute
+	// sourceRescan is how often the sources are checked for one due
+	sourceRescan = time.Minute
+	// maxSourceSize caps the size of a source response
+	maxSourceSize = 10 << 20
+)
+
+// Source is a JSON API links are imported from. Its response is either a
+// list of objects or, with ItemsField, an object holding that list; each
+// object becomes a link from the fields NameField and URLField name.
+type Source struct {
+	URL string `yaml:"url"`
+	// Group is the group the imported links go to, they are top-level
+	// links when it is empty
+	Group      string        `yaml:"group,omitempty"`
+	ItemsField string        `yaml:"items_field,omitempty"`
+	NameField  string        `yaml:"name_field,omitempty"`
+	URLField   string        `yaml:"url_field,omitempty"`
+	Interval   time.Duration `yaml:"interval,omitempty"`
+}
+
+// importedLinks are the links last fetched from a source
+type importedLinks struct {
+	links []Link
+	// next is when the source is due again, its interval moved by the
+	// jitter
+	next time.Time
+}
+
+// sourceImporter fetches the sources of the configuration in the
+// background and adds their links to it. A source that fails keeps the
+// links of its last successful fetch.
+type sourceImporter struct {
+	client  *http.Client
+	limiter *fetchLimiter
+	// jitter is the percentage by which each interval is randomly moved
+	jitter float64
+
+	mu      sync.Mutex
+	sources map[string]importedLinks // keyed by source URL
+}
+
+func newSourceImporter(jitter float64, client *http.Client, limiter *fetchLimiter) *sourceImporter {
+	return &sourceImporter{
+		client:  client,
+		limiter: limiter,
+		jitter:  jitter,
+		sources: make(map[string]importedLinks),
+	}
+}
+
+// apply returns config with the links imported from its sources, filled
+// in from config.Defaults. With dedupe, imported links whose URL is
+// already on the page are dropped like configured ones.
+func (s *sourceImporter) apply(config Configuration, dedupe bool) Configuration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	merged := false
+	for _, source := range config.Sources {
+		imported, ok := s.sources[source.URL]
+		if !ok || len(imported.links) == 0 {
+			continue
+		}
+		defaults := config.Defaults
+		links := mapLinks(Configuration{Links: imported.links}, func(link Link) Link {
+			return withDefaults(link, defaults)
+		})
+		if source.Group != "" {
+			links = Configuration{Groups: []Group{{Name: source.Group, Links: links.Links}}}
+		}
+		// Don't append to the slices shared with the handler's copy
+		config.Links = config.Links[:len(config.Links):len(config.Links)]
+		config.Groups = cloneGroups(config.Groups)
+		config = mergeConfigs(config, links)
+		merged = true
+	}
+	if merged && dedupe {
+		config, _ = dedupeLinks(config)
+	}
+	return config
+}
+
+// cloneGroups copies groups and their link slices so they can be appended
+// to without changing the original
+func cloneGroups(groups []Group) []Group {
+	cloned := make([]Group, len(groups))
+	for i, group := range groups {
+		group.Links = group.Links[:len(group.Links):len(group.Links)]
+		cloned[i] = group
+	}
+	return cloned
+}
+
+// run fetches the sources of the handler's configuration right away, then
+// every time their interval elapses, give or take the jitter. Rendered
+// pages are dropped when the imported links change.
+func (s *sourceImporter) run(ctx context.Context, handler *Handler) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		if s.refresh(ctx, handler.getConfig()) && handler.rendered != nil {
+			handler.rendered.invalidate()
+		}
+		timer.Reset(jitter(sourceRescan, s.jitter))
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// refresh fetches the sources of config that are due and reports whether
+// the links imported changed
+func (s *sourceImporter) refresh(ctx context.Context, config Configuration) (changed bool) {
+	now := time.Now()
+	for _, source := range config.Sources {
+		interval := source.Interval
+		if interval <= 0 {
+			interval = defaultSourceInterval
+		}
+		s.mu.Lock()
+		imported, ok := s.sources[source.URL]
+		s.mu.Unlock()
+		if ok && now.Before(imported.next) {
+			continue
+		}
+
+		links, err := s.fetch(ctx, source)
+		if err != nil {
+			slog.Warn("Failed to import links", "source", source.URL, "error", err)
+			links = imported.links
+		} else {
+			slog.Debug("Links imported", "source", source.URL, "links", len(links))
+			changed = changed || !reflect.DeepEqual(links, imported.links)
+		}
+		s.mu.Lock()
+		s.sources[source.URL] = importedLinks{links: links, next: now.Add(jitter(interval, s.jitter))}
+		s.mu.Unlock()
+	}
+	return changed
+}
+
+// fetch downloads source and maps its items to links
+func (s *sourceImporter) fetch(ctx context.Context, source Source) ([]Link, error) {
+	if err := s.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer s.limiter.release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceSize))
+	if err != nil {
+		return nil, err
+	}
+	links, err := mapSourceItems(data, source)
+	if err != nil {
+		return nil, err
+	}
+	// Imported links go through the same checks as the configured ones
+	imported := Configuration{Links: links}
+	for _, warning := range sanitizeLinks(&imported) {
+		slog.Warn("Ignoring invalid imported link", "source", source.URL, "warning", warning.String())
+	}
+	return imported.Links, nil
+}
+
+// mapSourceItems turns a source response into links. Items missing the
+// name or the URL field, or whose URL isn't http or https, are skipped.
+func mapSourceItems(data []byte, source Source) ([]Link, error) {
+	var items []map[string]any
+	if source.ItemsField == "" {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+	} else {
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, fmt.Errorf("invalid response: %w", err)
+		}
+		if err := json.Unmarshal(body[source.ItemsField], &items); err != nil {
+			return nil, fmt.Errorf("invalid %q field: %w", source.ItemsField, err)
+		}
+	}
+
+	nameField := cmp.Or(source.NameField, "name")
+	urlField := cmp.Or(source.URLField, "url")
+	links := make([]Link, 0, len(items))
+	for _, item := range items {
+		name, _ := item[nameField].(string)
+		rawURL, _ := item[urlField].(string)
+		if name == "" || !isWebURL(rawURL) {
+			continue
+		}
+		links = append(links, Link{Name: name, Url: rawURL})
+	}
+	return links, nil
+}
+
+// isWebURL reports whether rawURL is an absolute http or https URL
+func isWebURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Host != "" && (u.Scheme == "http" || u.Scheme == "https")
+}
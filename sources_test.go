@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// bookmarksResponse is the shape of the external bookmarks API
+const bookmarksResponse = `{"total": 4, "bookmarks": [
+	{"title": "Grafana", "href": "http://grafana.local", "tags": ["ops"]},
+	{"title": "Script", "href": "javascript:alert(1)"},
+	{"title": "", "href": "http://nameless.local"},
+	{"title": "Wiki", "href": "https://wiki.example.com"}
+]}`
+
+func TestSourceMapping(t *testing.T) {
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if fail.Load() {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(bookmarksResponse))
+	}))
+	defer server.Close()
+
+	config := Configuration{
+		Links:    []Link{{Name: "Router", Url: "http://router.local"}},
+		Defaults: Link{NewTab: true},
+		Sources: []Source{{
+			URL:        server.URL,
+			Group:      "Bookmarks",
+			ItemsField: "bookmarks",
+			NameField:  "title",
+			URLField:   "href",
+		}},
+	}
+	s := newSourceImporter(0, server.Client(), newFetchLimiter(0))
+	if !s.refresh(context.Background(), config) {
+		t.Error("refresh of a new source reported no change")
+	}
+	merged := s.apply(config, false)
+
+	if got := linkNames(merged.Links); !reflect.DeepEqual(got, []string{"Router"}) {
+		t.Errorf("top-level links = %v, want [Router]", got)
+	}
+	if len(merged.Groups) != 1 || merged.Groups[0].Name != "Bookmarks" {
+		t.Fatalf("groups = %v, want [Bookmarks]", groupNames(merged.Groups))
+	}
+	imported := merged.Groups[0].Links
+	want := []Link{
+		{Name: "Grafana", Url: "http://grafana.local", NewTab: true},
+		{Name: "Wiki", Url: "https://wiki.example.com", NewTab: true},
+	}
+	if !reflect.DeepEqual(imported, want) {
+		t.Errorf("imported links = %+v, want %+v", imported, want)
+	}
+	if len(config.Groups) != 0 {
+		t.Error("apply changed the configuration it was given")
+	}
+
+	// A failing fetch keeps the links of the last one
+	fail.Store(true)
+	s.sources[server.URL] = importedLinks{links: s.sources[server.URL].links, next: time.Now().Add(-time.Second)}
+	if s.refresh(context.Background(), config) {
+		t.Error("a failed fetch reported a change")
+	}
+	if got := linkNames(s.apply(config, false).Groups[0].Links); !reflect.DeepEqual(got, []string{"Grafana", "Wiki"}) {
+		t.Errorf("links after a failed fetch = %v, want the previous ones", got)
+	}
+}
+
+func TestImportInvalidatesRenderCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`[{"name": "Grafana", "url": "http://grafana.local"}]`))
+	}))
+	defer server.Close()
+
+	h := newTestHandler(t, Configuration{Hash: "1", Links: []Link{{Name: "Router", Url: "http://router.local"}}, Sources: []Source{{URL: server.URL}}})
+	h.rendered = newRenderCache(time.Hour)
+	h.imports = newSourceImporter(0, server.Client(), nil)
+	index := http.HandlerFunc(h.index)
+	if body := get(t, index, "/").Body.String(); strings.Contains(body, "Grafana") {
+		t.Fatal("the page has the imported link before the import")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.imports.run(ctx, h)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if strings.Contains(get(t, index, "/").Body.String(), "Grafana") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the cached page was served after the links were imported")
+		}
+	}
+}
+
+func TestMapSourceItems(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		source  Source
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "default fields",
+			data: `[{"name": "A", "url": "http://a.local"}, {"name": "B", "url": "ftp://b.local"}, {"name": "C"}]`,
+			want: []string{"A"},
+		},
+		{
+			name:   "items field",
+			data:   `{"items": [{"name": "A", "url": "https://a.local"}]}`,
+			source: Source{ItemsField: "items"},
+			want:   []string{"A"},
+		},
+		{name: "not a list", data: `{"name": "A"}`, wantErr: true},
+		{name: "missing items field", data: `{"other": []}`, source: Source{ItemsField: "items"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links, err := mapSourceItems([]byte(tt.data), tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mapSourceItems error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := linkNames(links); !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("links = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// stateFileName is the file of -state-dir the state is saved to
+	stateFileName = "state.json"
+	// stateFlushInterval is how often the state is saved while running
+	stateFlushInterval = 5 * time.Minute
+)
+
+// savedState is what is kept across restarts
+type savedState struct {
+	// Health is the last check of every link, keyed by URL
+	Health map[string]linkHealth `json:"health"`
+}
+
+// stateStore saves the link health to a JSON file of -state-dir, so the
+// page and /healthz?links=1 don't start blank after a restart
+type stateStore struct {
+	path string
+	// mode is the permission of the state file
+	mode os.FileMode
+}
+
+func newStateStore(dir string, mode os.FileMode) (*stateStore, error) {
+	if err := os.MkdirAll(dir, cacheDirMode(mode)); err != nil {
+		return nil, fmt.Errorf("failed to create state dir: %w", err)
+	}
+	return &stateStore{path: filepath.Join(dir, stateFileName), mode: mode}, nil
+}
+
+// load reads the saved state. A missing file is an empty state, a corrupt
+// one is an error.
+func (s *stateStore) load() (savedState, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return savedState{}, nil
+	}
+	if err != nil {
+		return savedState{}, err
+	}
+	var state savedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return savedState{}, fmt.Errorf("corrupt state file %s: %w", s.path, err)
+	}
+	return state, nil
+}
+
+// save replaces the state file with state, atomically so a crash can't
+// leave a truncated file behind
+func (s *stateStore) save(state savedState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return err
+	}
+	return os.Chmod(s.path, s.mode)
+}
+
+// restore loads the saved state into health. A corrupt file is logged and
+// ignored, the checks fill the state in again.
+func (s *stateStore) restore(health *healthChecker) {
+	state, err := s.load()
+	if err != nil {
+		slog.Warn("Ignoring saved state", "error", err)
+		return
+	}
+	health.restore(state.Health)
+	slog.Debug("State restored", "links", len(state.Health))
+}
+
+// flush saves the current state of health, failures are only logged
+func (s *stateStore) flush(health *healthChecker) {
+	if err := s.save(savedState{Health: health.results()}); err != nil {
+		slog.Warn("Failed to save state", "path", s.path, "error", err)
+	}
+}
+
+// run saves the state of health every stateFlushInterval until ctx is
+// done. The last flush, on shutdown, is left to the caller.
+func (s *stateStore) run(ctx context.Context, health *healthChecker) {
+	ticker := time.NewTicker(stateFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.flush(health)
+		}
+	}
+}
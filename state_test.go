@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStateStoreRoundTrip(t *testing.T) {
+	store, err := newStateStore(filepath.Join(t.TempDir(), "state"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := savedState{Health: map[string]linkHealth{
+		"http://up.local":   {Up: true, Code: 200, Checked: checked},
+		"http://down.local": {Err: "connection refused", Checked: checked, FrameBlocked: true},
+	}}
+	if err := store.save(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("load() = %+v, want %+v", got, want)
+	}
+
+	info, err := os.Stat(store.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("state file mode = %#o, want 0600", mode)
+	}
+	if err := store.save(savedState{}); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(store.path))
+	if len(entries) != 1 {
+		t.Errorf("state dir has %d files, want only %s", len(entries), stateFileName)
+	}
+}
+
+func TestStateStoreLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *string
+		wantErr bool
+	}{
+		{name: "missing file", content: nil},
+		{name: "empty object", content: ptr(`{}`)},
+		{name: "truncated", content: ptr(`{"health":{"http://a.local":{"up":tr`), wantErr: true},
+		{name: "not JSON", content: ptr("\x00\x01garbage"), wantErr: true},
+		{name: "wrong type", content: ptr(`{"health":[1,2]}`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := newStateStore(t.TempDir(), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.content != nil {
+				if err := os.WriteFile(store.path, []byte(*tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			state, err := store.load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(state.Health) != 0 {
+				t.Errorf("load() = %+v, want an empty state", state)
+			}
+		})
+	}
+}
+
+func TestStateStoreRestore(t *testing.T) {
+	store, err := newStateStore(t.TempDir(), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	saved := map[string]linkHealth{"http://up.local": {Up: true, Code: 200, Checked: now}}
+	if err := store.save(savedState{Health: saved}); err != nil {
+		t.Fatal(err)
+	}
+
+	checker := newHealthChecker(time.Hour, 0, 1, 0, http.DefaultClient, newFetchLimiter(0))
+	store.restore(checker)
+	if got := checker.status("http://up.local", now); got != statusUp {
+		t.Errorf("status after restore = %q, want %q", got, statusUp)
+	}
+
+	// A corrupt file leaves the checker as it was
+	if err := os.WriteFile(store.path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fresh := newHealthChecker(time.Hour, 0, 1, 0, http.DefaultClient, newFetchLimiter(0))
+	store.restore(fresh)
+	if got := fresh.results(); len(got) != 0 {
+		t.Errorf("results after a corrupt restore = %v, want none", got)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// newTLSConfig returns the TLS settings of the server, or nil when TLS is
+// disabled. With a client CA, clients must present a certificate signed
+// by it.
+func newTLSConfig(appConfig AppConfig) (*tls.Config, error) {
+	if appConfig.TLSCert == "" && appConfig.TLSKey == "" {
+		if appConfig.ClientCA != "" {
+			return nil, errors.New("-client-ca requires -tls-cert and -tls-key")
+		}
+		if appConfig.TLSCiphers != "" {
+			return nil, errors.New("-tls-ciphers requires -tls-cert and -tls-key")
+		}
+		return nil, nil
+	}
+	if appConfig.TLSCert == "" || appConfig.TLSKey == "" {
+		return nil, errors.New("-tls-cert and -tls-key must be set together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(appConfig.TLSCert, appConfig.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	minVersion, err := parseTLSVersion(appConfig.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	ciphers, err := parseCipherSuites(appConfig.TLSCiphers)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+		CipherSuites: ciphers,
+	}
+
+	if appConfig.ClientCA != "" {
+		pem, err := os.ReadFile(appConfig.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in client CA %s", appConfig.ClientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// tlsVersions are the accepted values of -tls-min-version
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func parseTLSVersion(version string) (uint16, error) {
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q: must be 1.0, 1.1, 1.2 or 1.3", version)
+	}
+	return v, nil
+}
+
+// parseCipherSuites maps a comma separated list of cipher suite names, as
+// spelled by crypto/tls, to their ids. Only suites without known security
+// issues are accepted. An empty list leaves the choice to crypto/tls. TLS
+// 1.3 suites aren't configurable and are always enabled.
+func parseCipherSuites(list string) ([]uint16, error) {
+	if list == "" {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	var ids []uint16
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert is a generated certificate and its key
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newTestCert generates a certificate for cn, signed by parent or
+// self-signed when parent is nil. CA certificates can sign others.
+func newTestCert(t *testing.T, cn string, parent *testCert, ca bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if ca {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// files writes the certificate and key as PEM files in dir
+func (c *testCert) files(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = writeFile(t, dir, name+".crt", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})))
+	keyFile = writeFile(t, dir, name+".key", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// newServerCert writes a CA and a server certificate it signed to dir,
+// returning the CA and an AppConfig serving TLS with the certificate
+func newServerCert(t *testing.T, dir string) (*testCert, AppConfig) {
+	t.Helper()
+	ca := newTestCert(t, "Test CA", nil, true)
+	certFile, keyFile := newTestCert(t, "127.0.0.1", ca, false).files(t, dir, "server")
+	return ca, AppConfig{TLSCert: certFile, TLSKey: keyFile, TLSMinVersion: "1.2"}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca, appConfig := newServerCert(t, dir)
+	appConfig.ClientCA, _ = ca.files(t, dir, "ca")
+	tlsConfig, err := newTLSConfig(appConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("ClientAuth = %v, want RequireAndVerifyClientCert", tlsConfig.ClientAuth)
+	}
+
+	buf := captureLog(t, slog.LevelInfo)
+	server := httptest.NewUnstartedServer(logRequests(http.HandlerFunc(servePing), false, 1))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+
+	resp, err := client(newTestCert(t, "kiosk", ca, false).tlsCertificate()).Get(server.URL + "/ping")
+	if err != nil {
+		t.Fatalf("request with a valid client certificate: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if !strings.Contains(buf.String(), "client_cn=kiosk") {
+		t.Errorf("the client CN is not logged:\n%s", buf)
+	}
+
+	other := newTestCert(t, "Other CA", nil, true)
+	for name, c := range map[string]*http.Client{
+		"no certificate":            client(),
+		"certificate of another CA": client(newTestCert(t, "intruder", other, false).tlsCertificate()),
+	} {
+		if resp, err := c.Get(server.URL + "/ping"); err == nil {
+			resp.Body.Close()
+			t.Errorf("request with %s succeeded", name)
+		}
+	}
+}
+
+func TestClientCAErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, appConfig := newServerCert(t, dir)
+	tests := map[string]AppConfig{
+		"missing CA file":              {TLSCert: appConfig.TLSCert, TLSKey: appConfig.TLSKey, TLSMinVersion: "1.2", ClientCA: filepath.Join(dir, "missing.pem")},
+		"CA file without certificates": {TLSCert: appConfig.TLSCert, TLSKey: appConfig.TLSKey, TLSMinVersion: "1.2", ClientCA: writeFile(t, dir, "empty.pem", "")},
+		"CA without TLS":               {ClientCA: appConfig.TLSCert},
+	}
+	for name, c := range tests {
+		if _, err := newTLSConfig(c); err == nil {
+			t.Errorf("%s: newTLSConfig succeeded", name)
+		}
+	}
+}
+
+func TestTLSVersionAndCiphers(t *testing.T) {
+	_, appConfig := newServerCert(t, t.TempDir())
+	appConfig.TLSMinVersion = "1.3"
+	appConfig.TLSCiphers = "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"
+	tlsConfig, err := newTLSConfig(appConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("MinVersion = %x, want TLS 1.3", tlsConfig.MinVersion)
+	}
+	want := []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}
+	if !slices.Equal(tlsConfig.CipherSuites, want) {
+		t.Errorf("CipherSuites = %x, want %x", tlsConfig.CipherSuites, want)
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	_, appConfig := newServerCert(t, t.TempDir())
+	tests := []struct {
+		name    string
+		version string
+		ciphers string
+		want    string
+	}{
+		{"unknown cipher", "1.2", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_FAKE", `unknown or insecure cipher suite "TLS_FAKE"`},
+		{"insecure cipher", "1.2", "TLS_RSA_WITH_RC4_128_SHA", `unknown or insecure cipher suite "TLS_RSA_WITH_RC4_128_SHA"`},
+		{"unknown version", "1.4", "", `unknown TLS version "1.4"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := appConfig
+			c.TLSMinVersion, c.TLSCiphers = tt.version, tt.ciphers
+			_, err := newTLSConfig(c)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("newTLSConfig = %v, want an error containing %s", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := newTLSConfig(AppConfig{TLSCiphers: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}); err == nil {
+		t.Error("-tls-ciphers without a certificate succeeded")
+	}
+}
+
+func TestTLSRejectsOldVersions(t *testing.T) {
+	ca, appConfig := newServerCert(t, t.TempDir())
+	tlsConfig, err := newTLSConfig(appConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("MinVersion = %x, want TLS 1.2", tlsConfig.MinVersion)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(servePing))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	for _, version := range []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13} {
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{
+			RootCAs:    roots,
+			MinVersion: version,
+			MaxVersion: version,
+		})
+		if err == nil {
+			conn.Close()
+		}
+		if accepted, want := err == nil, version >= tls.VersionTLS12; accepted != want {
+			t.Errorf("%s handshake accepted = %t (%v), want %t", tls.VersionName(version), accepted, err, want)
+		}
+	}
+}
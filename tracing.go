@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// traceFlushInterval is how often finished spans are exported
+	traceFlushInterval = 5 * time.Second
+	// maxPendingSpans caps the spans waiting for export, the oldest are
+	// dropped when the collector can't keep up
+	maxPendingSpans = 2048
+)
+
+// OTLP span kinds
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+)
+
+// traceSpan is a finished or running operation, exported in the OTLP JSON
+// encoding
+type traceSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []spanAttribute `json:"attributes,omitempty"`
+	Status       spanStatus      `json:"status"`
+
+	start time.Time
+}
+
+type spanAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type spanStatus struct {
+	// Code is 0 for unset and 2 for error
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func stringAttribute(key, value string) spanAttribute {
+	return spanAttribute{Key: key, Value: map[string]any{"stringValue": value}}
+}
+
+func intAttribute(key string, value int) spanAttribute {
+	// OTLP JSON encodes 64-bit integers as strings
+	return spanAttribute{Key: key, Value: map[string]any{"intValue": strconv.Itoa(value)}}
+}
+
+// tracer records spans for requests and reloads and sends them to an
+// OTLP/HTTP collector in the background. A nil tracer records nothing.
+type tracer struct {
+	endpoint string
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending []traceSpan
+}
+
+func newTracer(endpoint string, client *http.Client) *tracer {
+	return &tracer{endpoint: endpoint, client: client}
+}
+
+// randomID returns n random bytes in hex, as trace and span ids are
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parseTraceparent returns the trace and parent span ids of a W3C
+// traceparent header, or empty strings when it isn't valid
+func parseTraceparent(header string) (traceID, spanID string) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", ""
+	}
+	for _, id := range parts[1:3] {
+		if _, err := hex.DecodeString(id); err != nil || strings.Trim(id, "0") == "" {
+			return "", ""
+		}
+	}
+	return strings.ToLower(parts[1]), strings.ToLower(parts[2])
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// withSpan returns ctx carrying s, the parent of the spans started from it
+func withSpan(ctx context.Context, s *traceSpan) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// start begins a span under the span of ctx, or in a new trace when ctx
+// carries none. It returns nil on a nil tracer.
+func (t *tracer) start(ctx context.Context, name string, kind int) *traceSpan {
+	if t == nil {
+		return nil
+	}
+	traceID, parentID := randomID(16), ""
+	if parent, ok := ctx.Value(spanKey{}).(*traceSpan); ok {
+		traceID, parentID = parent.TraceID, parent.SpanID
+	}
+	return &traceSpan{
+		TraceID:      traceID,
+		SpanID:       randomID(8),
+		ParentSpanID: parentID,
+		Name:         name,
+		Kind:         kind,
+		start:        time.Now(),
+	}
+}
+
+// end finishes s, marking it failed when err is set, and queues it for
+// export
+func (t *tracer) end(s *traceSpan, err error) {
+	if t == nil || s == nil {
+		return
+	}
+	s.Start = strconv.FormatInt(s.start.UnixNano(), 10)
+	s.End = strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err != nil {
+		s.Status = spanStatus{Code: 2, Message: err.Error()}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= maxPendingSpans {
+		t.pending = t.pending[1:]
+	}
+	t.pending = append(t.pending, *s)
+}
+
+// traceRequests records a server span for every request answered by next,
+// continuing the trace of an incoming traceparent header. The span is put
+// in the request context so the work next does is traced under it.
+func (t *tracer) traceRequests(next http.Handler) http.Handler {
+	if t == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if traceID, parentID := parseTraceparent(req.Header.Get("Traceparent")); traceID != "" {
+			ctx = withSpan(ctx, &traceSpan{TraceID: traceID, SpanID: parentID})
+		}
+		s := t.start(ctx, req.Method, spanKindServer)
+		req = req.WithContext(withSpan(ctx, s))
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, req)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		// The mux fills in the pattern of the route that matched
+		if req.Pattern != "" {
+			s.Name = req.Method + " " + req.Pattern
+			s.Attributes = append(s.Attributes, stringAttribute("http.route", req.Pattern))
+		}
+		s.Attributes = append(s.Attributes,
+			stringAttribute("http.request.method", req.Method),
+			stringAttribute("url.path", req.URL.Path),
+			intAttribute("http.response.status_code", rec.status),
+		)
+		var err error
+		if rec.status >= 500 {
+			err = fmt.Errorf("status %d", rec.status)
+		}
+		t.end(s, err)
+	})
+}
+
+// run exports the pending spans every traceFlushInterval, and a last time
+// once ctx is done
+func (t *tracer) run(ctx context.Context) {
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), traceFlushInterval)
+			t.flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			t.flush(ctx)
+		}
+	}
+}
+
+// flush sends the pending spans to the collector. Spans that fail to send
+// are dropped, tracing must never get in the way of serving.
+func (t *tracer) flush(ctx context.Context) {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []spanAttribute{
+				stringAttribute("service.name", "home"),
+				stringAttribute("service.version", version),
+			}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "home"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		slog.Warn("Failed to encode spans", "error", err)
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("Failed to export spans", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		slog.Warn("Failed to export spans", "spans", len(spans), "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		slog.Warn("Failed to export spans", "spans", len(spans), "status", resp.Status)
+	}
+}
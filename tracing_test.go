@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// otlpRequest is the part of an OTLP/HTTP JSON export the tests look at
+type otlpRequest struct {
+	ResourceSpans []struct {
+		ScopeSpans []struct {
+			Spans []traceSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+// newCollector starts an OTLP/HTTP collector recording the spans it is
+// sent
+func newCollector(t *testing.T) (*httptest.Server, func() []traceSpan) {
+	t.Helper()
+	var mu sync.Mutex
+	var spans []traceSpan
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("collector got %s with Content-Type %q", req.Method, req.Header.Get("Content-Type"))
+		}
+		var body otlpRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("collector got an invalid body: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, resource := range body.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				spans = append(spans, scope.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []traceSpan {
+		mu.Lock()
+		defer mu.Unlock()
+		return spans
+	}
+}
+
+func attribute(s traceSpan, key string) any {
+	for _, attr := range s.Attributes {
+		if attr.Key == key {
+			for _, value := range attr.Value {
+				return value
+			}
+		}
+	}
+	return nil
+}
+
+func TestTraceRequestsExportsOneServerSpan(t *testing.T) {
+	collector, spans := newCollector(t)
+	tr := newTracer(collector.URL, collector.Client())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", servePing)
+	handler := tr.traceRequests(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	tr.flush(context.Background())
+
+	got := spans()
+	if len(got) != 1 {
+		t.Fatalf("collector got %d spans, want 1: %+v", len(got), got)
+	}
+	s := got[0]
+	if s.Kind != spanKindServer || s.Name != "GET /ping" {
+		t.Errorf("span kind %d name %q, want %d %q", s.Kind, s.Name, spanKindServer, "GET /ping")
+	}
+	if s.TraceID != "0af7651916cd43dd8448eb211c80319c" || s.ParentSpanID != "b7ad6b7169203331" {
+		t.Errorf("span trace %s parent %s, want the incoming traceparent", s.TraceID, s.ParentSpanID)
+	}
+	if code := attribute(s, "http.response.status_code"); code != "200" {
+		t.Errorf("status code attribute = %v, want \"200\"", code)
+	}
+	if s.Start == "" || s.End == "" || s.Status.Code != 0 {
+		t.Errorf("span times %q-%q status %+v, want a finished span without error", s.Start, s.End, s.Status)
+	}
+
+	// Exported spans are not sent again
+	tr.flush(context.Background())
+	if n := len(spans()); n != 1 {
+		t.Errorf("collector got %d spans after a second flush, want 1", n)
+	}
+}
+
+func TestTraceRequestsMarksServerErrors(t *testing.T) {
+	collector, spans := newCollector(t)
+	tr := newTracer(collector.URL, collector.Client())
+	handler := tr.traceRequests(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	tr.flush(context.Background())
+
+	got := spans()
+	if len(got) != 1 {
+		t.Fatalf("collector got %d spans, want 1", len(got))
+	}
+	if got[0].Status.Code != 2 || got[0].ParentSpanID != "" || len(got[0].TraceID) != 32 {
+		t.Errorf("span = %+v, want an error status in a new trace", got[0])
+	}
+}
+
+func TestReloadSpanIsChildOfRequest(t *testing.T) {
+	collector, spans := newCollector(t)
+	h := newTestHandler(t, Configuration{})
+	h.configFile = writeFile(t, t.TempDir(), "config.yaml", "links:\n  - {name: NAS, url: http://nas.local}\n")
+	h.tracer = newTracer(collector.URL, collector.Client())
+	handler := h.tracer.traceRequests(newTestMux(t, h, AppConfig{ReloadToken: "token"}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/reload", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reload = %d %s, want 200", rec.Code, rec.Body)
+	}
+	h.tracer.flush(context.Background())
+
+	got := spans()
+	if len(got) != 2 {
+		t.Fatalf("collector got %d spans, want the reload and the request", len(got))
+	}
+	reload, request := got[0], got[1]
+	if reload.Name != "config.reload" || request.Name != "POST /api/reload" {
+		t.Fatalf("spans %q, %q, want config.reload then POST /api/reload", reload.Name, request.Name)
+	}
+	if reload.TraceID != request.TraceID || reload.ParentSpanID != request.SpanID {
+		t.Errorf("reload span trace %s parent %s, want a child of request span %s in trace %s", reload.TraceID, reload.ParentSpanID, request.SpanID, request.TraceID)
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tr *tracer
+	next := http.HandlerFunc(servePing)
+	if handler := tr.traceRequests(next); handler == nil {
+		t.Fatal("traceRequests on a nil tracer returned nil")
+	}
+	s := tr.start(context.Background(), "config.reload", spanKindInternal)
+	if s != nil {
+		t.Errorf("start on a nil tracer = %+v, want nil", s)
+	}
+	tr.end(s, errors.New("ignored"))
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header        string
+		trace, parent string
+	}{
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331"},
+		{" 00-0AF7651916CD43DD8448EB211C80319C-B7AD6B7169203331-00 ", "0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331"},
+		{"", "", ""},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331", "", ""},
+		{"00-00000000000000000000000000000000-b7ad6b7169203331-01", "", ""},
+		{"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01", "", ""},
+		{"00-zzf7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "", ""},
+		{"00-0af7651916cd43dd-b7ad6b7169203331-01", "", ""},
+	}
+	for _, tt := range tests {
+		trace, parent := parseTraceparent(tt.header)
+		if trace != tt.trace || parent != tt.parent {
+			t.Errorf("parseTraceparent(%q) = %q, %q, want %q, %q", tt.header, trace, parent, tt.trace, tt.parent)
+		}
+	}
+}
+
+// OTLP/JSON trace export, as defined by ExportTraceServiceRequest in
+// opentelemetry-proto, limited to the fields spans may carry. Decoding
+// with unknown fields disallowed catches keys the schema doesn't have.
+type (
+	otlpExport struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource struct {
+			Attributes []otlpKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpScopeSpans struct {
+		Scope struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes"`
+		Status            struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"status"`
+	}
+	otlpKeyValue struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue *string  `json:"stringValue"`
+			BoolValue   *bool    `json:"boolValue"`
+			IntValue    *string  `json:"intValue"`
+			DoubleValue *float64 `json:"doubleValue"`
+		} `json:"value"`
+	}
+)
+
+// checkOTLPAttributes checks every attribute holds exactly one value, a
+// decimal string for integers as the protobuf JSON mapping of int64 is
+func checkOTLPAttributes(t *testing.T, attributes []otlpKeyValue) {
+	t.Helper()
+	for _, attr := range attributes {
+		v := attr.Value
+		set := 0
+		for _, ok := range []bool{v.StringValue != nil, v.BoolValue != nil, v.IntValue != nil, v.DoubleValue != nil} {
+			if ok {
+				set++
+			}
+		}
+		if attr.Key == "" || set != 1 {
+			t.Errorf("attribute %q has %d values, want a key and one value", attr.Key, set)
+		}
+		if v.IntValue != nil {
+			if _, err := strconv.ParseInt(*v.IntValue, 10, 64); err != nil {
+				t.Errorf("attribute %q intValue %q is not a decimal int64", attr.Key, *v.IntValue)
+			}
+		}
+	}
+}
+
+func TestExportMatchesOTLPSchema(t *testing.T) {
+	var bodies [][]byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, body)
+	}))
+	defer collector.Close()
+	tr := newTracer(collector.URL, collector.Client())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", servePing)
+	tr.traceRequests(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+	tr.end(tr.start(context.Background(), "config.reload", spanKindInternal), errors.New("invalid config"))
+	tr.flush(context.Background())
+
+	if len(bodies) != 1 {
+		t.Fatalf("collector got %d exports, want 1", len(bodies))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(bodies[0]))
+	decoder.DisallowUnknownFields()
+	var export otlpExport
+	if err := decoder.Decode(&export); err != nil {
+		t.Fatalf("export doesn't match the OTLP schema: %v\n%s", err, bodies[0])
+	}
+	if len(export.ResourceSpans) != 1 || len(export.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("export = %s, want one resource and one scope", bodies[0])
+	}
+	resource := export.ResourceSpans[0]
+	checkOTLPAttributes(t, resource.Resource.Attributes)
+	if i := slices.IndexFunc(resource.Resource.Attributes, func(kv otlpKeyValue) bool { return kv.Key == "service.name" }); i < 0 || *resource.Resource.Attributes[i].Value.StringValue != "home" {
+		t.Errorf("resource attributes = %+v, want service.name home", resource.Resource.Attributes)
+	}
+
+	hexID := regexp.MustCompile(`^[0-9a-f]+$`)
+	scope := resource.ScopeSpans[0]
+	if scope.Scope.Name == "" || len(scope.Spans) != 2 {
+		t.Fatalf("scope %q with %d spans, want a named scope with 2", scope.Scope.Name, len(scope.Spans))
+	}
+	for _, s := range scope.Spans {
+		if len(s.TraceID) != 32 || !hexID.MatchString(s.TraceID) || len(s.SpanID) != 16 || !hexID.MatchString(s.SpanID) {
+			t.Errorf("%s: trace %q span %q, want 16 and 8 bytes in lowercase hex", s.Name, s.TraceID, s.SpanID)
+		}
+		if s.ParentSpanID != "" && (len(s.ParentSpanID) != 16 || !hexID.MatchString(s.ParentSpanID)) {
+			t.Errorf("%s: parent %q, want 8 bytes in lowercase hex", s.Name, s.ParentSpanID)
+		}
+		// SpanKind runs from UNSPECIFIED (0) to CONSUMER (5), StatusCode
+		// from UNSET (0) to ERROR (2)
+		if s.Name == "" || s.Kind < 1 || s.Kind > 5 || s.Status.Code < 0 || s.Status.Code > 2 {
+			t.Errorf("span %q kind %d status %d out of the OTLP enums", s.Name, s.Kind, s.Status.Code)
+		}
+		start, err1 := strconv.ParseUint(s.StartTimeUnixNano, 10, 64)
+		end, err2 := strconv.ParseUint(s.EndTimeUnixNano, 10, 64)
+		if err1 != nil || err2 != nil || start == 0 || end < start {
+			t.Errorf("%s: times %q-%q, want decimal fixed64 nanoseconds in order", s.Name, s.StartTimeUnixNano, s.EndTimeUnixNano)
+		}
+		checkOTLPAttributes(t, s.Attributes)
+	}
+	if reload := scope.Spans[1]; reload.Kind != spanKindInternal || reload.Status.Code != 2 || reload.Status.Message != "invalid config" {
+		t.Errorf("reload span = %+v, want an internal span with an error status", reload)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func TestVersionJSON(t *testing.T) {
+	mux := newTestMux(t, newTestHandler(t, Configuration{}), AppConfig{})
+	rec := get(t, mux, "/version.json")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /version.json = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var doc map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"version", "commit", "date", "go_version"} {
+		if doc[key] == "" {
+			t.Errorf("%s is missing from %s", key, rec.Body)
+		}
+	}
+}
+
+func TestVersionFields(t *testing.T) {
+	defer func(v, c, d string) { version, commit, date = v, c, d }(version, commit, date)
+	version, commit, date = "1.2.3", "abc123", "2024-05-01T10:00:00Z"
+
+	rec := get(t, http.HandlerFunc(serveVersion), "/version")
+	var info buildInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	want := buildInfo{Version: "1.2.3", Commit: "abc123", Date: "2024-05-01T10:00:00Z", GoVersion: runtime.Version()}
+	if info != want {
+		t.Errorf("GET /version = %+v, want %+v", info, want)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Warning is a problem found in the configuration that didn't stop it from
+// loading
+type Warning struct {
+	// Link is the name of the link concerned, if any
+	Link    string `json:"link,omitempty"`
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	if w.Link == "" {
+		return w.Message
+	}
+	return fmt.Sprintf("%s: %s", w.Link, w.Message)
+}
+
+// LoadResult is a loaded configuration with the warnings raised while
+// loading it
+type LoadResult struct {
+	Config   Configuration
+	Warnings []Warning
+	// files are the raw configuration files Config was assembled from
+	files []configFile
+}
+
+// sanitizeLinks removes the links that can't be served and the aliases
+// that can't work from config, returning a warning for each. Links missing
+// a name or a URL, or with an unsafe URL, class or alias, are skipped; an alias
+// already taken, or naming a page or a route, is dropped from the later link.
+func sanitizeLinks(config *Configuration) []Warning {
+	var warnings []Warning
+	aliases := make(map[string]string)
+	keep := func(link *Link) bool {
+		switch {
+		case link.Name == "":
+			warnings = append(warnings, Warning{Message: fmt.Sprintf("link to %q has no name", link.Url)})
+			return false
+		case link.Url == "":
+			warnings = append(warnings, Warning{Link: link.Name, Message: "no URL"})
+			return false
+		case isScriptURL(link.Url):
+			warnings = append(warnings, Warning{Link: link.Name, Message: fmt.Sprintf("unsafe URL %q, javascript:, vbscript: and data: URLs are not allowed", link.Url)})
+			return false
+		case !validClass.MatchString(link.Class):
+			warnings = append(warnings, Warning{Link: link.Name, Message: fmt.Sprintf("invalid class %q, only letters, digits, '-', '_' and spaces are allowed", link.Class)})
+			return false
+		}
+		for _, alias := range link.aliasNames() {
+			if alias == "" || strings.Contains(alias, "/") {
+				warnings = append(warnings, Warning{Link: link.Name, Message: fmt.Sprintf("invalid alias %q, aliases can't be empty or contain '/'", alias)})
+				return false
+			}
+		}
+		// claim reports whether alias is free and takes it for the link
+		claim := func(alias string) bool {
+			if isReservedName(alias) {
+				warnings = append(warnings, Warning{Link: link.Name, Message: fmt.Sprintf("alias %q is taken by a route of the server", alias)})
+				return false
+			}
+			if _, ok := config.Pages[alias]; ok {
+				warnings = append(warnings, Warning{Link: link.Name, Message: fmt.Sprintf("alias %q is the name of a page", alias)})
+				return false
+			}
+			if owner, ok := aliases[alias]; ok {
+				warnings = append(warnings, Warning{Link: link.Name, Message: fmt.Sprintf("alias %q is already used by %q", alias, owner)})
+				return false
+			}
+			aliases[alias] = link.Name
+			return true
+		}
+		if link.Alias != "" && !claim(link.Alias) {
+			link.Alias = ""
+		}
+		link.Aliases = slices.DeleteFunc(slices.Clone(link.Aliases), func(alias string) bool {
+			return !claim(alias)
+		})
+		return true
+	}
+	sanitize := func(links []Link) []Link {
+		var kept []Link
+		for _, link := range links {
+			if keep(&link) {
+				kept = append(kept, link)
+			}
+		}
+		return kept
+	}
+	sanitizeGroups := func(groups []Group) {
+		for i := range groups {
+			groups[i].Links = sanitize(groups[i].Links)
+		}
+	}
+	config.Links = sanitize(config.Links)
+	sanitizeGroups(config.Groups)
+	for _, name := range pageNames(*config) {
+		page := config.Pages[name]
+		page.Links = sanitize(page.Links)
+		sanitizeGroups(page.Groups)
+		config.Pages[name] = page
+	}
+	return warnings
+}
+
+// apiWarnings answers /api/warnings with the warnings raised loading the
+// current configuration
+func (h *Handler) apiWarnings(w http.ResponseWriter, req *http.Request) {
+	h.mu.RLock()
+	warnings := struct {
+		Hash     string    `json:"hash"`
+		Warnings []Warning `json:"warnings"`
+	}{h.config.Hash, h.warnings}
+	h.mu.RUnlock()
+	if warnings.Warnings == nil {
+		warnings.Warnings = []Warning{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(warnings)
+}
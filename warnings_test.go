@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestInvalidClass(t *testing.T) {
+	for _, class := range []string{`x" onmouseover="alert(1)`, "a;b", "<b>", "ops\tnext"} {
+		content := "links:\n  - {name: Bad, url: http://bad.local, class: " + strconv.Quote(class) + "}\n  - {name: Good, url: http://good.local, class: ops}\n"
+
+		result, err := loadString(t, content, loadOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := linkNames(result.Config.Links); !reflect.DeepEqual(got, []string{"Good"}) {
+			t.Errorf("class %q: links = %v, want the link skipped", class, got)
+		}
+		if len(result.Warnings) != 1 || result.Warnings[0].Link != "Bad" || !strings.Contains(result.Warnings[0].Message, "invalid class") {
+			t.Errorf("class %q: warnings = %v", class, result.Warnings)
+		}
+
+		if _, err := loadString(t, content, loadOptions{strict: true}); err == nil {
+			t.Errorf("class %q was accepted under -strict", class)
+		}
+	}
+}
+
+const partlyInvalidConfig = `
+links:
+  - {name: Router, url: "http://router.local"}
+  - {name: Nowhere}
+  - {url: "http://nameless.local"}
+  - {name: Script, url: "javascript:alert(1)"}
+groups:
+  - name: Media
+    links:
+      - {name: Jellyfin, url: "http://jellyfin.local"}
+      - {name: Bad alias, url: "http://bad.local", alias: "a/b"}
+`
+
+func TestLenientLoad(t *testing.T) {
+	result, err := loadString(t, partlyInvalidConfig, loadOptions{})
+	if err != nil {
+		t.Fatalf("lenient load failed: %v", err)
+	}
+	if got := linkNames(result.Config.Links); !reflect.DeepEqual(got, []string{"Router"}) {
+		t.Errorf("links = %v, want [Router]", got)
+	}
+	if got := linkNames(result.Config.Groups[0].Links); !reflect.DeepEqual(got, []string{"Jellyfin"}) {
+		t.Errorf("Media links = %v, want [Jellyfin]", got)
+	}
+	var got []string
+	for _, warning := range result.Warnings {
+		got = append(got, warning.Link)
+	}
+	if want := []string{"Nowhere", "", "Script", "Bad alias"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("warnings for %q, want %q: %v", got, want, result.Warnings)
+	}
+}
+
+func TestStrictLoad(t *testing.T) {
+	_, err := loadString(t, partlyInvalidConfig, loadOptions{strict: true})
+	if err == nil || !strings.Contains(err.Error(), "-strict") || !strings.Contains(err.Error(), "Nowhere") {
+		t.Errorf("strict load error = %v, want the first invalid link", err)
+	}
+	if _, err := loadString(t, "links:\n  - {name: Router, url: http://router.local}\n", loadOptions{strict: true}); err != nil {
+		t.Errorf("strict load of a valid config failed: %v", err)
+	}
+}
+
+func TestAPIWarnings(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.yaml", "links:\n  - {name: Router, url: http://router.local}\n  - {name: Nowhere}\n")
+	h := newTestHandler(t, Configuration{})
+	if _, _, err := h.reload(context.Background(), path); err != nil {
+		t.Fatal(err)
+	}
+	mux := newTestMux(t, h, AppConfig{})
+
+	var body struct {
+		Hash     string    `json:"hash"`
+		Warnings []Warning `json:"warnings"`
+	}
+	rec := get(t, mux, "/api/warnings")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /api/warnings = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := []Warning{{Link: "Nowhere", Message: "no URL"}}
+	if !reflect.DeepEqual(body.Warnings, want) || body.Hash != h.getConfig().Hash {
+		t.Errorf("GET /api/warnings = %+v, want %v for the current hash", body, want)
+	}
+
+	// A reload replaces the warnings, an empty list is sent as []
+	writeFile(t, filepath.Dir(path), "config.yaml", "links:\n  - {name: Router, url: http://router.local}\n")
+	if _, _, err := h.reload(context.Background(), path); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(get(t, mux, "/api/warnings").Body.String()); !strings.HasSuffix(got, `"warnings":[]}`) {
+		t.Errorf("GET /api/warnings after a clean reload = %s, want no warnings", got)
+	}
+}
@@ -0,0 +1,85 @@
+//go:build !no_watch
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSupported is false in builds with the no_watch tag, which leave the
+// watcher and fsnotify out
+const watchSupported = true
+
+// configDebounce is how long the watcher waits for file events to stop
+// before reloading
+const configDebounce = 100 * time.Millisecond
+
+func watchConfig(configPath string, handler *Handler) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fatal("Failed to create config watcher", "error", err)
+	}
+	defer watcher.Close()
+
+	// Watch the directories, not the files (Kubernetes uses symlinks)
+	files, err := expandConfigSource(configPath)
+	if err != nil {
+		fatal("Failed to expand config pattern", "error", err)
+	}
+	dirs := make([]string, 0, len(files)+1)
+	for _, file := range files {
+		dirs = append(dirs, filepath.Dir(file))
+	}
+	if handler.templatesDir != "" {
+		dirs = append(dirs, handler.templatesDir)
+	}
+	if handler.load.categoriesDir != "" {
+		dirs = append(dirs, handler.load.categoriesDir)
+	}
+	watched := make(map[string]bool)
+	for _, dir := range dirs {
+		if watched[dir] {
+			continue
+		}
+		watched[dir] = true
+		if err := watcher.Add(dir); err != nil {
+			fatal("Failed to watch config directory", "dir", dir, "error", err)
+		}
+	}
+
+	// Saving a file is often several events, the reload waits for them to
+	// settle
+	debounce := time.NewTimer(configDebounce)
+	debounce.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if isAtomicTempFile(event.Name) {
+				continue
+			}
+			// Kubernetes updates ConfigMaps by updating symlinks
+			if event.Op&fsnotify.Create == fsnotify.Create ||
+				event.Op&fsnotify.Write == fsnotify.Write {
+				slog.Debug("Config file changed", "file", event.Name)
+				debounce.Reset(configDebounce)
+			}
+		case <-debounce.C:
+			if _, _, err := handler.reload(context.Background(), configPath); err != nil {
+				slog.Error("Error reloading config", "error", err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Error("Watcher error", "error", err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// whoamiHeaders are the request headers echoed by /whoami, the ones
+// reverse proxies set
+var whoamiHeaders = []string{"Host", "X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Real-Ip", "Forwarded"}
+
+// parseTrustedProxies parses a comma separated list of IP addresses and
+// CIDR prefixes
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(item); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither an IP address nor a CIDR prefix", item)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// isTrusted reports whether addr belongs to one of the trusted proxies
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	return slices.ContainsFunc(trusted, func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	})
+}
+
+// clientIP returns the address of the client behind req. When the peer is
+// a trusted proxy, X-Forwarded-For is walked from the right and the first
+// address that isn't a trusted proxy is the client.
+func clientIP(req *http.Request, trusted []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !isTrusted(peer, trusted) {
+		return host
+	}
+	var hops []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	client := host
+	for _, hop := range slices.Backward(hops) {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hop))
+		if err != nil {
+			break
+		}
+		client = addr.String()
+		if !isTrusted(addr, trusted) {
+			break
+		}
+	}
+	return client
+}
+
+// requestScheme returns the scheme the client used, the X-Forwarded-Proto
+// of a trusted proxy winning over the one of the connection
+func requestScheme(req *http.Request, trusted []netip.Prefix) string {
+	host, _, _ := net.SplitHostPort(req.RemoteAddr)
+	if peer, err := netip.ParseAddr(host); err == nil && isTrusted(peer, trusted) {
+		if proto := req.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// serveWhoami answers /whoami with what the server sees of the request,
+// to debug reverse proxy setups
+func serveWhoami(trusted []netip.Prefix) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		headers := make(map[string]string)
+		for _, name := range whoamiHeaders {
+			value := strings.Join(req.Header.Values(name), ", ")
+			if name == "Host" {
+				value = req.Host
+			}
+			if value != "" {
+				headers[name] = value
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]any{
+			"remote_addr": req.RemoteAddr,
+			"client_ip":   clientIP(req, trusted),
+			"scheme":      requestScheme(req, trusted),
+			"host":        req.Host,
+			"headers":     headers,
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWhoamiTrustedXFF(t *testing.T) {
+	trusted, err := parseTrustedProxies("10.0.0.1, 192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := newTestMux(t, newTestHandler(t, Configuration{}), AppConfig{Whoami: true, TrustedProxies: trusted})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		proto      string
+		wantIP     string
+		wantScheme string
+	}{
+		{"trusted proxy", "10.0.0.1:4242", []string{"203.0.113.7"}, "https", "203.0.113.7", "https"},
+		{"proxy chain", "10.0.0.1:4242", []string{"198.51.100.1, 203.0.113.7, 192.168.1.10"}, "", "203.0.113.7", "http"},
+		{"several headers", "192.168.1.10:4242", []string{"198.51.100.1", "203.0.113.7"}, "", "203.0.113.7", "http"},
+		{"untrusted peer", "203.0.113.50:4242", []string{"1.2.3.4"}, "https", "203.0.113.50", "http"},
+		{"no header", "10.0.0.1:4242", nil, "", "10.0.0.1", "http"},
+		{"forged garbage", "10.0.0.1:4242", []string{"not-an-ip"}, "", "10.0.0.1", "http"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, xff := range tt.xff {
+				req.Header.Add("X-Forwarded-For", xff)
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			var body struct {
+				RemoteAddr string            `json:"remote_addr"`
+				ClientIP   string            `json:"client_ip"`
+				Scheme     string            `json:"scheme"`
+				Headers    map[string]string `json:"headers"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.ClientIP != tt.wantIP || body.Scheme != tt.wantScheme || body.RemoteAddr != tt.remoteAddr {
+				t.Errorf("whoami = %+v, want client %s over %s", body, tt.wantIP, tt.wantScheme)
+			}
+			if body.Headers["Host"] != "example.com" {
+				t.Errorf("Host = %q, want example.com", body.Headers["Host"])
+			}
+		})
+	}
+}
+
+func TestWhoamiDisabled(t *testing.T) {
+	if rec := get(t, newTestMux(t, newTestHandler(t, Configuration{}), AppConfig{}), "/whoami"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /whoami without -whoami = %d, want 404", rec.Code)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies("10.0.0.1,  ::1 ,172.16.5.4/12,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1/32", "::1/128", "172.16.0.0/12"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("prefixes = %v, want %v", prefixes, want)
+	}
+	for i, prefix := range prefixes {
+		if prefix.String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, prefix, want[i])
+		}
+	}
+	if _, err := parseTrustedProxies("10.0.0.1, proxy.local"); err == nil {
+		t.Error("a host name was accepted")
+	}
+}
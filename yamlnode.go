@@ -0,0 +1,108 @@
+package main
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// preserveLayout carries the comments, key order and scalar styles of old
+// over to updated, its re-encoded version. Mapping keys are matched by
+// name and list items by their name field, falling back to the position,
+// so an edit only loses the comments of what it removed.
+func preserveLayout(old, updated *yaml.Node) {
+	if old.Kind != updated.Kind {
+		return
+	}
+	updated.HeadComment = old.HeadComment
+	updated.LineComment = old.LineComment
+	updated.FootComment = old.FootComment
+
+	switch updated.Kind {
+	case yaml.ScalarNode:
+		if old.Value == updated.Value {
+			updated.Style = old.Style
+		}
+	case yaml.MappingNode:
+		preserveMapping(old, updated)
+	case yaml.SequenceNode:
+		for i, item := range updated.Content {
+			if match := matchItem(old, item, i); match != nil {
+				preserveLayout(match, item)
+			}
+		}
+	}
+}
+
+// preserveMapping reorders the pairs of updated to follow old, new keys
+// going last, and preserves the layout of every key and value
+func preserveMapping(old, updated *yaml.Node) {
+	oldPairs := make(map[string][2]*yaml.Node)
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		oldPairs[old.Content[i].Value] = [2]*yaml.Node{old.Content[i], old.Content[i+1]}
+	}
+	newPairs := make(map[string][2]*yaml.Node)
+	var added []*yaml.Node
+	for i := 0; i+1 < len(updated.Content); i += 2 {
+		key, value := updated.Content[i], updated.Content[i+1]
+		newPairs[key.Value] = [2]*yaml.Node{key, value}
+		if pair, ok := oldPairs[key.Value]; ok {
+			preserveLayout(pair[0], key)
+			preserveLayout(pair[1], value)
+		} else {
+			added = append(added, key, value)
+		}
+	}
+
+	content := make([]*yaml.Node, 0, len(updated.Content))
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		if pair, ok := newPairs[old.Content[i].Value]; ok {
+			content = append(content, pair[0], pair[1])
+		}
+	}
+	updated.Content = append(content, added...)
+}
+
+// keepUnknownKeys copies the top-level keys of old that aren't
+// configuration settings, such as the ones holding YAML anchors, over to
+// updated which can't have them
+func keepUnknownKeys(old, updated *yaml.Node) {
+	if old.Kind != yaml.MappingNode || updated.Kind != yaml.MappingNode {
+		return
+	}
+	known := configSchema()["properties"].(map[string]any)
+	var unknown []*yaml.Node
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		if _, ok := known[old.Content[i].Value]; !ok {
+			unknown = append(unknown, old.Content[i], old.Content[i+1])
+		}
+	}
+	updated.Content = append(unknown, updated.Content...)
+}
+
+// matchItem returns the item of the old sequence updated item i comes from:
+// the one with the same name field, else the one at the same position
+func matchItem(old, item *yaml.Node, i int) *yaml.Node {
+	if name := nameField(item); name != "" {
+		for _, candidate := range old.Content {
+			if nameField(candidate) == name {
+				return candidate
+			}
+		}
+	}
+	if i < len(old.Content) {
+		return old.Content[i]
+	}
+	return nil
+}
+
+// nameField returns the value of the name key of a mapping node
+func nameField(node *yaml.Node) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "name" {
+			return node.Content[i+1].Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const commentedConfig = `# Home dashboard
+title: Home # shown in the tab
+links:
+  # The router comes first
+  - name: Router
+    url: "http://router.local"
+  - name: Wiki # team notes
+    url: http://wiki.local
+`
+
+func TestEditKeepsComments(t *testing.T) {
+	h, path := newEditingHandler(t, commentedConfig)
+	_, err := h.editor.edit(func(c *Configuration) error {
+		c.Links[1].Url = "https://wiki.example.com"
+		c.Links = append(c.Links, Link{Name: "Grafana", Url: "http://grafana.local"})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(data)
+	for _, want := range []string{
+		"# Home dashboard\n",
+		"title: Home # shown in the tab\n",
+		"  # The router comes first\n",
+		"  - name: Wiki # team notes\n",
+		`    url: "http://router.local"` + "\n",
+		"url: https://wiki.example.com\n",
+		"name: Grafana\n",
+	} {
+		if !strings.Contains(saved, want) {
+			t.Errorf("saved file does not contain %q:\n%s", want, saved)
+		}
+	}
+}
+
+func TestEditKeepsKeyOrder(t *testing.T) {
+	h, path := newEditingHandler(t, "links:\n  - name: Router\n    url: http://router.local\ntitle: Home\n")
+	if _, err := h.editor.edit(func(c *Configuration) error {
+		c.Title = "Lab"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if links, title := strings.Index(string(data), "links:"), strings.Index(string(data), "title: Lab"); links < 0 || title < links {
+		t.Errorf("the keys were reordered:\n%s", data)
+	}
+}